@@ -0,0 +1,53 @@
+package tbotworkflow
+
+import (
+	"context"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// executeCallback answers the CallbackQuery and feeds its data
+// into the current step as if the user had sent it as text.
+func (w *TBotWorkflowController) executeCallback(ctx context.Context, callback *tgbotapi.CallbackQuery,
+	sendFunc func(c tgbotapi.Chattable) (tgbotapi.Message, error)) (*UserInputs, Result, error) {
+	// Callbacks from inline mode messages do not carry the message and cannot be tied to a chat.
+	if callback.Message == nil || callback.Message.Chat == nil {
+		w.Logger.Printf("Ignoring callback %s without message", callback.ID)
+		w.answerCallback(callback, sendFunc)
+		return nil, ResultNotFound, nil
+	}
+
+	// The callback is answered by the workflows, within the Middleware and the lock of the chat.
+	return w.handle(ctx, callbackMessage(callback), callback, sendFunc)
+}
+
+// callbackMessage returns a Message from the user carrying the callback data as text.
+// The media of the message the keyboard is attached to is not carried over.
+func callbackMessage(callback *tgbotapi.CallbackQuery) *tgbotapi.Message {
+	return &tgbotapi.Message{
+		MessageID: callback.Message.MessageID,
+		From:      callback.From,
+		Date:      callback.Message.Date,
+		Chat:      callback.Message.Chat,
+		Text:      callback.Data,
+	}
+}
+
+func (w *TBotWorkflowController) answerCallback(callback *tgbotapi.CallbackQuery,
+	sendFunc func(c tgbotapi.Chattable) (tgbotapi.Message, error)) {
+	answer := tgbotapi.NewCallback(callback.ID, "")
+	if w.RequestFunc == nil {
+		sendFunc(answer)
+		return
+	}
+	if _, err := w.RequestFunc(answer); err != nil {
+		w.Logger.Printf("Failed answering callback. Error: %v", err)
+	}
+}
+
+// removeInlineKB removes the inline keyboard from the message the callback originated from.
+func (w *TBotWorkflowController) removeInlineKB(callback *tgbotapi.CallbackQuery, s *sender) {
+	edit := tgbotapi.NewEditMessageReplyMarkup(callback.Message.Chat.ID, callback.Message.MessageID,
+		tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}})
+	s.send(edit)
+}
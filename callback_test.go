@@ -0,0 +1,104 @@
+package tbotworkflow
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestInlineKeyboardWorkflow(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	sentChattables = []tgbotapi.Chattable{}
+
+	wfc := NewWorkflowController("WFC")
+	inlineWF := newInlineWorkflow("CMD3")
+	mustAddWorkflow(t, wfc, &inlineWF)
+
+	cmd := mockBotCommand(1, "/CMD3")
+	userInput, done := wfc.ExecuteUpdate(tgbotapi.Update{Message: &cmd}, mockSendFunc)
+	if done || userInput != nil {
+		t.Fatal("Workflow completed at step 1 but should not have")
+	}
+	if _, ok := sentChattables[0].(tgbotapi.MessageConfig).ReplyMarkup.(*tgbotapi.InlineKeyboardMarkup); !ok {
+		t.Errorf("Expected inline keyboard to be sent but got %T", sentChattables[0].(tgbotapi.MessageConfig).ReplyMarkup)
+	}
+
+	// Callback data not on the keyboard is rejected.
+	userInput, done = wfc.ExecuteUpdate(mockCallbackUpdate(1, "cb1", "size_xxl"), mockSendFunc)
+	if done || userInput != nil {
+		t.Fatal("Workflow completed for invalid callback data")
+	}
+	expectedMsg := "Invalid input size_xxl. Please try again"
+	if sentMsgs[1].Text != expectedMsg {
+		t.Errorf("Expected \"%s\" message to be sent. But \"%s\" sent instead", expectedMsg, sentMsgs[1].Text)
+	}
+
+	sentChattables = []tgbotapi.Chattable{}
+	userInput, done = wfc.ExecuteUpdate(mockCallbackUpdate(1, "cb2", "size_m"), mockSendFunc)
+	if done || userInput != nil {
+		t.Fatal("Workflow completed at step 2 but should not have")
+	}
+	if answer, ok := sentChattables[0].(tgbotapi.CallbackConfig); !ok || answer.CallbackQueryID != "cb2" {
+		t.Errorf("Expected callback cb2 to be answered first but got %v", sentChattables[0])
+	}
+	if _, ok := sentChattables[1].(tgbotapi.EditMessageReplyMarkupConfig); !ok {
+		t.Errorf("Expected inline keyboard to be removed but got %T", sentChattables[1])
+	}
+
+	msg := mockBotMessage(1, "Step2Option1")
+	userInput, done = wfc.ExecuteUpdate(tgbotapi.Update{Message: &msg}, mockSendFunc)
+	if !done || userInput == nil {
+		t.Fatal("Expected workflow to complete at step 3")
+	}
+	if userInput.Data["Size"] != "size_m" {
+		t.Errorf("Expected Size: size_m but got %s", userInput.Data["Size"])
+	}
+
+	if userInput, done = wfc.ExecuteUpdate(tgbotapi.Update{}, mockSendFunc); done || userInput != nil {
+		t.Error("Expected empty update to be ignored")
+	}
+	sentMsgs = []tgbotapi.Message{}
+	sentChattables = []tgbotapi.Chattable{}
+}
+
+func mockCallbackUpdate(chatID int64, id string, data string) tgbotapi.Update {
+	return tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:   id,
+			Data: data,
+			From: &tgbotapi.User{
+				ID:       1234,
+				UserName: "UnitTest",
+			},
+			Message: &tgbotapi.Message{
+				MessageID: 2,
+				Text:      "Please select a size",
+				Chat: &tgbotapi.Chat{
+					ID: chatID,
+				},
+			},
+		},
+	}
+}
+
+func newInlineWorkflow(cmd string) TBotWorkflow {
+	sizeKB := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("S", "size_s"),
+			tgbotapi.NewInlineKeyboardButtonData("M", "size_m"),
+			tgbotapi.NewInlineKeyboardButtonData("L", "size_l"),
+		),
+	)
+	step1 := NewWorkflowStep("Step1", "Size", "Please select a size", nil)
+	step1.InlineKB = &sizeKB
+	step1.EditCallbackMessage = true
+
+	step2KB := getSingleButtonKeyboard("Step2Option1")
+	step2 := NewWorkflowStep("Step2", "K2", "Please select an option", &step2KB)
+	step3 := NewWorkflowStep("Step3", "", "Done", nil)
+
+	step1.Next = &step2
+	step2.Next = &step3
+
+	return NewWorkflow("InlineWF", cmd, &step1)
+}
@@ -0,0 +1,63 @@
+// Command wfgraph renders the workflows defined in tbotworkflow JSON documents
+// as Graphviz DOT or Mermaid diagrams.
+//
+// Usage:
+//
+//	wfgraph [-format dot|mermaid|markdown] workflows.json...
+//
+// The Go functions referenced by the documents are not needed to draw the diagrams
+// and are replaced by stubs, so the documents can be rendered outside of the bot.
+// Bots can render the workflows registered on their controller with WriteGraphs.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/hbbtekademy/tbotworkflow"
+)
+
+func main() {
+	format := flag.String("format", tbotworkflow.GraphDOT, "Output format: dot, mermaid or markdown (Mermaid diagrams in Markdown sections)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-format dot|mermaid|markdown] workflows.json...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	wfc := tbotworkflow.NewWorkflowController("wfgraph")
+	for _, path := range flag.Args() {
+		if err := addWorkflows(wfc, path); err != nil {
+			log.Fatalf("Failed loading %s. Error: %v", path, err)
+		}
+	}
+	if err := wfc.WriteGraphs(os.Stdout, *format); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// addWorkflows loads the workflows of the document at path into the controller.
+func addWorkflows(wfc *tbotworkflow.TBotWorkflowController, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	wfs, err := tbotworkflow.LoadWorkflows(f, tbotworkflow.NewStubFuncRegistry())
+	if err != nil {
+		return err
+	}
+	for _, wf := range wfs {
+		if err := wfc.AddWorkflow(wf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
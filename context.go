@@ -0,0 +1,98 @@
+package tbotworkflow
+
+import (
+	"context"
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// ExecuteContext runs one of the registered workflows given a Message from the user.
+// Same as ExecuteE, but ctx is passed to the Middleware and the context aware functions of the steps and the controller.
+// If ctx is done, or one of the context aware functions returns an error, the message is not processed any further.
+// ResultAborted is returned with the error, and the progress of the user is left as it was before the message,
+// so that the message can be processed again.
+// Side effects which happened before the error are not undone and happen again when the message is processed again:
+// the messages already sent, the events sent to the Recorder, and the hooks and Metrics of the workflows started
+// and the steps left or entered, e.g. OnStart, OnStepExit, OnStepEnter and MetricStarts.
+// A ReplyTextContextFunc is run once the user moved to its step, after these side effects.
+// The other context aware functions are run before the user leaves the CurrentStep.
+func (w *TBotWorkflowController) ExecuteContext(ctx context.Context, msg *tgbotapi.Message,
+	sendFunc func(c tgbotapi.Chattable) (tgbotapi.Message, error)) (*UserInputs, Result, error) {
+	return w.handle(ctx, msg, nil, sendFunc)
+}
+
+// ExecuteUpdateContext runs one of the registered workflows given an Update from the user.
+// Same as ExecuteContext, but also processes the CallbackQuery of inline keyboard buttons.
+// Updates without a Message or CallbackQuery are ignored and return ResultNotFound without an error.
+func (w *TBotWorkflowController) ExecuteUpdateContext(ctx context.Context, update tgbotapi.Update,
+	sendFunc func(c tgbotapi.Chattable) (tgbotapi.Message, error)) (*UserInputs, Result, error) {
+	if update.CallbackQuery != nil {
+		return w.executeCallback(ctx, update.CallbackQuery, sendFunc)
+	}
+	if update.Message != nil {
+		return w.handle(ctx, update.Message, nil, sendFunc)
+	}
+	return nil, ResultNotFound, nil
+}
+
+// contextErr returns err, or the error of ctx if the function returned after ctx was done.
+func contextErr(ctx context.Context, err error) error {
+	if err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// hasCondition tells if the next step is determined by a ConditionFunc or ConditionContextFunc.
+func (s *TBotWorkflowStep) hasCondition() bool {
+	return s.ConditionFunc != nil || s.ConditionContextFunc != nil
+}
+
+// condition returns the output of the ConditionContextFunc, or else the ConditionFunc of the step.
+func (s *TBotWorkflowStep) condition(ctx context.Context, msg *tgbotapi.Message) (string, error) {
+	if s.ConditionContextFunc == nil {
+		return s.ConditionFunc(msg), nil
+	}
+	cond, err := s.ConditionContextFunc(ctx, msg)
+	if err = contextErr(ctx, err); err != nil {
+		return "", fmt.Errorf("ConditionContextFunc of Step: %s failed: %w", s.Name, err)
+	}
+	return cond, nil
+}
+
+// replyText returns the output of the ReplyTextContextFunc, or else the ReplyTextFunc of the step.
+// Returns false if neither is set.
+func (s *TBotWorkflowStep) replyText(ctx context.Context, ui *UserInputs) (string, bool, error) {
+	if s.ReplyTextContextFunc != nil {
+		text, err := s.ReplyTextContextFunc(ctx, ui)
+		if err = contextErr(ctx, err); err != nil {
+			return "", true, fmt.Errorf("ReplyTextContextFunc of Step: %s failed: %w", s.Name, err)
+		}
+		return text, true, nil
+	}
+	if s.ReplyTextFunc != nil {
+		return s.ReplyTextFunc(ui), true, nil
+	}
+	return "", false, nil
+}
+
+// validateInputContext runs the validation function, passing ctx to it if it is context aware.
+// Returns false if neither is set.
+func validateInputContext(ctx context.Context, msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup,
+	fn func(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool),
+	ctxFn func(ctx context.Context, msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool, error),
+) (invalidReplyText string, ok bool, set bool, err error) {
+	if ctxFn != nil {
+		invalidReplyText, ok, err = ctxFn(ctx, msg, kb)
+		if err = contextErr(ctx, err); err != nil {
+			return "", false, true, fmt.Errorf("ValidateInputContextFunc failed: %w", err)
+		}
+		return invalidReplyText, ok, true, nil
+	}
+	if fn != nil {
+		invalidReplyText, ok = fn(msg, kb)
+		return invalidReplyText, ok, true, nil
+	}
+	return "", false, false, nil
+}
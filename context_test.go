@@ -0,0 +1,205 @@
+package tbotworkflow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type traceIDKey struct{}
+
+func TestExecuteContextCancelled(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	seqWF := newSeqWorkflow("CMD1")
+	mustAddWorkflow(t, wfc, &seqWF)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	msg := mockBotCommand(1, "/CMD1")
+	userInput, result, err := wfc.ExecuteContext(ctx, &msg, mockSendFunc)
+	if userInput != nil || result != ResultAborted || !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected aborted execution but got %v/%v/%v", userInput, result, err)
+	}
+	if len(sentMsgs) != 0 {
+		t.Errorf("Expected no messages sent but got %d", len(sentMsgs))
+	}
+	if session := wfc.currentSession(&msg); session != nil {
+		t.Errorf("Expected no session started but got %v", session)
+	}
+}
+
+func TestConditionContextFunc(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	condWF := newCondWorkflow("CMD1")
+	condStep2 := condWF.RootStep.Next
+	condStep2.ConditionContextFunc = func(ctx context.Context, msg *tgbotapi.Message) (string, error) {
+		if ctx.Value(traceIDKey{}) != "trace-1" {
+			t.Errorf("Expected the context of ExecuteContext but got %v", ctx)
+		}
+		if msg.Text == "Step2Condition2" {
+			// Waits for a slow backend until the deadline.
+			<-ctx.Done()
+			return "", ctx.Err()
+		}
+		return "C1", nil
+	}
+	mustAddWorkflow(t, wfc, &condWF)
+
+	ctx := context.WithValue(context.Background(), traceIDKey{}, "trace-1")
+	for _, text := range []string{"/CMD1", "Step1Option1"} {
+		msg := mockBotMessage(1, text)
+		if text[0] == '/' {
+			msg = mockBotCommand(1, text)
+		}
+		wfc.ExecuteContext(ctx, &msg, mockSendFunc)
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	msg := mockBotMessage(1, "Step2Condition2")
+	userInput, result, err := wfc.ExecuteContext(timeoutCtx, &msg, mockSendFunc)
+	if userInput != nil || result != ResultAborted || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded but got %v/%v/%v", userInput, result, err)
+	}
+	if session := wfc.currentSession(&msg); session == nil || session.StepID != condStep2.id() {
+		t.Errorf("Expected the user to stay at CondStep2 but got %v", session)
+	}
+
+	msg = mockBotMessage(1, "Step2Condition1")
+	if _, result, err := wfc.ExecuteContext(ctx, &msg, mockSendFunc); result != ResultInProgress || err != nil {
+		t.Errorf("Expected workflow to move on but got %v/%v", result, err)
+	}
+	if session := wfc.currentSession(&msg); session == nil || session.StepID != condStep2.ConditionalNext["C1"].id() {
+		t.Errorf("Expected the user at C1Step3 but got %v", session)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestValidateInputContextFunc(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	seqWF := newSeqWorkflow("CMD1")
+	backendErr := errors.New("backend unavailable")
+	seqWF.RootStep.ValidateInputContextFunc = func(ctx context.Context, msg *tgbotapi.Message,
+		kb *tgbotapi.ReplyKeyboardMarkup) (string, bool, error) {
+		switch msg.Text {
+		case "Step1Option1":
+			return "", true, nil
+		case "Step1Option2":
+			return "", false, backendErr
+		}
+		return "Option not available", false, nil
+	}
+	// The context aware function takes priority.
+	seqWF.RootStep.ValidateInputFunc = func(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool) {
+		t.Error("Expected ValidateInputFunc not to be called")
+		return "", true
+	}
+	wfc.ValidateInputContextFunc = func(ctx context.Context, msg *tgbotapi.Message,
+		kb *tgbotapi.ReplyKeyboardMarkup) (string, bool, error) {
+		return "", false, context.Canceled
+	}
+	mustAddWorkflow(t, wfc, &seqWF)
+
+	msg := mockBotCommand(1, "/CMD1")
+	wfc.ExecuteContext(context.Background(), &msg, mockSendFunc)
+
+	msg = mockBotMessage(1, "Step1Option2")
+	if _, result, err := wfc.ExecuteContext(context.Background(), &msg, mockSendFunc); result != ResultAborted || !errors.Is(err, backendErr) {
+		t.Errorf("Expected the error of the validator but got %v/%v", result, err)
+	}
+	msg = mockBotMessage(1, "Step1Option3")
+	if _, result, _ := wfc.ExecuteContext(context.Background(), &msg, mockSendFunc); result != ResultValidationFailed {
+		t.Errorf("Expected validation failure but got %v", result)
+	}
+	if reply := sentMsgs[len(sentMsgs)-2].Text; reply != "Option not available" {
+		t.Errorf("Expected reply of the validator but got %s", reply)
+	}
+	msg = mockBotMessage(1, "Step1Option1")
+	if _, result, _ := wfc.ExecuteContext(context.Background(), &msg, mockSendFunc); result != ResultInProgress {
+		t.Errorf("Expected valid input but got %v", result)
+	}
+
+	// Step2 falls back to the context aware function of the controller.
+	msg = mockBotMessage(1, "Step2Option1")
+	if _, result, err := wfc.ExecuteContext(context.Background(), &msg, mockSendFunc); result != ResultAborted || !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the error of the controller validator but got %v/%v", result, err)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestReplyTextContextFunc(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	seqWF := newSeqWorkflow("CMD1")
+	fail := true
+	seqWF.RootStep.Next.ReplyTextContextFunc = func(ctx context.Context, ui *UserInputs) (string, error) {
+		if fail {
+			return "", errors.New("template service down")
+		}
+		return "You selected " + ui.Data["K1"], nil
+	}
+	mustAddWorkflow(t, wfc, &seqWF)
+
+	msg := mockBotCommand(1, "/CMD1")
+	wfc.ExecuteContext(context.Background(), &msg, mockSendFunc)
+	msg = mockBotMessage(1, "Step1Option2")
+	if _, result, err := wfc.ExecuteContext(context.Background(), &msg, mockSendFunc); result != ResultAborted || err == nil {
+		t.Errorf("Expected aborted execution but got %v/%v", result, err)
+	}
+
+	fail = false
+	if _, result, err := wfc.ExecuteContext(context.Background(), &msg, mockSendFunc); result != ResultInProgress || err != nil {
+		t.Errorf("Expected retried input to move on but got %v/%v", result, err)
+	}
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "You selected Step1Option2" {
+		t.Errorf("Expected the text of ReplyTextContextFunc but got %s", reply)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestReplyTextContextFuncChatScoped(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	seqWF := newSeqWorkflow("CMD1")
+	condWF := newCondWorkflow("CMD2")
+	condWF.ChatScoped = true
+	condWF.RootStep.ReplyTextContextFunc = func(ctx context.Context, ui *UserInputs) (string, error) {
+		return "", errors.New("template service down")
+	}
+	mustAddWorkflow(t, wfc, &seqWF)
+	mustAddWorkflow(t, wfc, &condWF)
+
+	// The user's own session is kept when the chat scoped workflow is aborted.
+	runMessages(wfc, 1, "/CMD1")
+	msg := mockBotCommand(1, "/CMD2")
+	if _, result, err := wfc.ExecuteContext(context.Background(), &msg, mockSendFunc); result != ResultAborted || err == nil {
+		t.Errorf("Expected aborted execution but got %v/%v", result, err)
+	}
+	if session := wfc.currentSession(&msg); session == nil || session.UserInputs.Command != "CMD1" {
+		t.Errorf("Expected the session of CMD1 to be kept but got %+v", session)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestWorkflowNotFoundReplyTextContextFunc(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	wfc.WorkflowNotFoundReplyTextContextFunc = func(ctx context.Context, msg *tgbotapi.Message) (string, error) {
+		return "Unknown command " + msg.Text, nil
+	}
+
+	msg := mockBotCommand(1, "/CMD9")
+	if _, result, _ := wfc.ExecuteContext(context.Background(), &msg, mockSendFunc); result != ResultNotFound {
+		t.Errorf("Expected ResultNotFound but got %v", result)
+	}
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Unknown command /CMD9" {
+		t.Errorf("Expected the text of WorkflowNotFoundReplyTextContextFunc but got %s", reply)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
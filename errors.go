@@ -0,0 +1,102 @@
+package tbotworkflow
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Result tells what happened to the workflow of the user for a given message.
+type Result int
+
+const (
+	// ResultInProgress means the workflow is waiting for the next user input.
+	ResultInProgress Result = iota
+	// ResultCompleted means the workflow has ended and the UserInputs are available.
+	ResultCompleted
+	// ResultCancelled means the user pressed the cancel button.
+	ResultCancelled
+	// ResultValidationFailed means the user input was rejected and the step is repeated.
+	ResultValidationFailed
+	// ResultNotFound means there is no workflow for the command or the user is not in a workflow.
+	ResultNotFound
+	// ResultExpired means the progress of the user was discarded due to inactivity.
+	ResultExpired
+	// ResultBroken means the workflow could not determine the next step and was ended.
+	ResultBroken
+	// ResultAborted means the context was done, a context aware function failed or Recover recovered from a panic.
+	// The progress of the user is left as it was before the message.
+	ResultAborted
+)
+
+func (r Result) String() string {
+	switch r {
+	case ResultInProgress:
+		return "in-progress"
+	case ResultCompleted:
+		return "completed"
+	case ResultCancelled:
+		return "cancelled"
+	case ResultValidationFailed:
+		return "validation-failed"
+	case ResultNotFound:
+		return "not-found"
+	case ResultExpired:
+		return "expired"
+	case ResultBroken:
+		return "broken"
+	case ResultAborted:
+		return "aborted"
+	}
+	return fmt.Sprintf("Result(%d)", int(r))
+}
+
+var (
+	// ErrWorkflowNotFound is returned when there is no workflow for the command
+	// or a message is received from a user who is not in a workflow.
+	ErrWorkflowNotFound = errors.New("workflow not found")
+	// ErrBrokenWorkflow is returned when the next step cannot be determined,
+	// i.e. the ConditionFunc output has no ConditionalNext step.
+	ErrBrokenWorkflow = errors.New("workflow broken")
+	// ErrInputNotFound is returned by the UserInputs accessors when the user did not provide an input for the key.
+	ErrInputNotFound = errors.New("input not found")
+)
+
+// SendError is returned when the send function fails to send a message to the user.
+type SendError struct {
+	// The message that could not be sent.
+	Chattable tgbotapi.Chattable
+	// Error returned by the send function.
+	Err error
+}
+
+func (e *SendError) Error() string {
+	return fmt.Sprintf("failed sending message: %v", e.Err)
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// sender sends the messages for a single execution of a workflow
+// and keeps the first error that occurred.
+type sender struct {
+	sendFunc func(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	logger   *log.Logger
+	err      error
+}
+
+func (s *sender) send(c tgbotapi.Chattable) {
+	if _, err := s.sendFunc(c); err != nil {
+		s.logger.Printf("Failed sending message. Error: %v", err)
+		s.fail(&SendError{Chattable: c, Err: err})
+	}
+}
+
+func (s *sender) fail(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
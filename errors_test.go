@@ -0,0 +1,93 @@
+package tbotworkflow
+
+import (
+	"errors"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestExecuteE(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	condWF := newCondWorkflow("CMD2")
+	mustAddWorkflow(t, wfc, &condWF)
+	// Condition C2 has no next step.
+	delete(condWF.RootStep.Next.ConditionalNext, "C2")
+
+	tests := []struct {
+		text           string
+		expectedResult Result
+		expectedErr    error
+	}{
+		{text: "/XYZ", expectedResult: ResultNotFound, expectedErr: ErrWorkflowNotFound},
+		{text: "Step1Option1", expectedResult: ResultNotFound, expectedErr: ErrWorkflowNotFound},
+		{text: "/CMD2", expectedResult: ResultInProgress},
+		{text: "Step9Option9", expectedResult: ResultValidationFailed},
+		{text: "Step1Option1", expectedResult: ResultInProgress},
+		{text: "RESET", expectedResult: ResultCancelled},
+		{text: "/CMD2", expectedResult: ResultInProgress},
+		{text: "Step1Option1", expectedResult: ResultInProgress},
+		{text: "Step2Condition2", expectedResult: ResultBroken, expectedErr: ErrBrokenWorkflow},
+		{text: "/CMD2", expectedResult: ResultInProgress},
+		{text: "Step1Option1", expectedResult: ResultInProgress},
+		{text: "Step2Condition1", expectedResult: ResultInProgress},
+		{text: "C1Step3Option1", expectedResult: ResultCompleted},
+	}
+
+	for i, tc := range tests {
+		msg := mockBotMessage(1, tc.text)
+		if tc.text[0] == '/' {
+			msg = mockBotCommand(1, tc.text)
+		}
+
+		userInput, result, err := wfc.ExecuteE(&msg, mockSendFunc)
+		if result != tc.expectedResult {
+			t.Errorf("Step %d/Input:%s Expected result %v but got %v", i+1, tc.text, tc.expectedResult, result)
+		}
+		if tc.expectedErr == nil && err != nil {
+			t.Errorf("Step %d/Input:%s Expected no error but got %v", i+1, tc.text, err)
+		}
+		if tc.expectedErr != nil && !errors.Is(err, tc.expectedErr) {
+			t.Errorf("Step %d/Input:%s Expected error %v but got %v", i+1, tc.text, tc.expectedErr, err)
+		}
+		if (result == ResultCompleted) != (userInput != nil) {
+			t.Errorf("Step %d/Input:%s Expected UserInputs only for completed workflow but got %v", i+1, tc.text, userInput)
+		}
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestExecuteESendError(t *testing.T) {
+	errTelegram := errors.New("Forbidden: bot was blocked by the user")
+	failingSendFunc := func(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+		return tgbotapi.Message{}, errTelegram
+	}
+
+	wfc := NewWorkflowController("WFC")
+	seqWF := newSeqWorkflow("CMD1")
+	mustAddWorkflow(t, wfc, &seqWF)
+
+	msg := mockBotCommand(1, "/CMD1")
+	userInput, result, err := wfc.ExecuteE(&msg, failingSendFunc)
+	if result != ResultInProgress || userInput != nil {
+		t.Errorf("Expected workflow in progress but got %v", result)
+	}
+
+	var sendErr *SendError
+	if !errors.As(err, &sendErr) {
+		t.Fatalf("Expected SendError but got %v", err)
+	}
+	if !errors.Is(err, errTelegram) {
+		t.Errorf("Expected SendError to wrap the send function error but got %v", sendErr.Err)
+	}
+	if sendErr.Chattable.(tgbotapi.MessageConfig).Text != "Please select an option" {
+		t.Errorf("Expected the failed message in the SendError but got %v", sendErr.Chattable)
+	}
+
+	// Execute still reports the workflow progress.
+	msg = mockBotMessage(1, "Step1Option1")
+	if userInput, done := wfc.Execute(&msg, failingSendFunc); done || userInput != nil {
+		t.Error("Workflow completed at step 2 but should not have")
+	}
+}
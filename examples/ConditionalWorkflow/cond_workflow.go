@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/hbbtekademy/tbotworkflow"
@@ -96,41 +99,40 @@ func main() {
 		log.Fatalf("Failed creating BotAPI. Error: %v", err)
 	}
 
-	// Subscribe to the Bot updates
-	u := tgbotapi.NewUpdate(-1)
-	u.Timeout = 60
-	updates := botAPI.GetUpdatesChan(u)
-
 	// Create a TBotWorkflow
 	wf := getCondWorkflow()
 
-	// Create new Workflow Controller
+	// Create new Workflow Controller and a Runner receiving the Bot updates
 	wfc := tbotworkflow.NewWorkflowController("WFC")
-	// Add the Workflow to the controller. Any number of workflows can be added to a Workflow Controller.
-	wfc.AddWorkflow(wf)
-
-	// Process the Telegram Bot Updates
-	for update := range updates {
-		// Do any pre-processing before invoking the workflows here.
-
-		// Execute the workflow
-		userInputs, done := wfc.Execute(update.Message, botAPI.Send)
-		if !done {
-			continue
+	runner := tbotworkflow.NewRunner(botAPI, wfc)
+
+	// Add the Workflow with the function handling the user inputs once a user completes the workflow.
+	// Any number of workflows can be added. The workflow steps are validated when the workflow is added.
+	err = runner.AddWorkflow(wf, func(userInputs *tbotworkflow.UserInputs) {
+		// The tags are the "Key" fields of the steps.
+		var params struct {
+			Action string `tbot:"ACAction"`
+			Name   string `tbot:"ACName"`
+			Temp   string `tbot:"ACTemp"`
+			Fan    string `tbot:"ACFanSpeed"`
 		}
-
-		// Handle the user inputs as required.
-		cmd := userInputs.Command
-		uid := userInputs.UID
-		if cmd == "AC_CONTROL" {
-			action := userInputs.Data["ACAction"] // ACAction is the "Key" field in the step
-			acName := userInputs.Data["ACName"]
-			temp := userInputs.Data["ACTemp"]
-			fan := userInputs.Data["ACFanSpeed"]
-
-			log.Printf("UID: %d, Command: %s, Params[Action: %s, Name: %s, Temp: %s, Fan: %s]",
-				uid, cmd, action, acName, temp, fan)
+		if err := userInputs.Decode(&params); err != nil {
+			log.Printf("Failed decoding user inputs. Error: %v", err)
+			return
 		}
+
+		log.Printf("UID: %d, Command: %s, Params[Action: %s, Name: %s, Temp: %s, Fan: %s]",
+			userInputs.UID, userInputs.Command, params.Action, params.Name, params.Temp, params.Fan)
+	})
+	if err != nil {
+		log.Fatalf("Failed adding workflow. Error: %v", err)
+	}
+
+	// Process the Telegram Bot Updates until the bot is stopped with Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := runner.Run(ctx); err != nil {
+		log.Fatalf("Failed running bot. Error: %v", err)
 	}
 }
 
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/hbbtekademy/tbotworkflow"
+)
+
+var (
+	botToken string = "Put your Bot TOKEN here"
+)
+
+// getFuncRegistry returns the Go functions referenced by name in workflows.json
+func getFuncRegistry() *tbotworkflow.FuncRegistry {
+	registry := tbotworkflow.NewFuncRegistry()
+
+	registry.RegisterCondition("acAction", func(msg *tgbotapi.Message) string {
+		switch msg.Text {
+		case "Quick Start":
+			return "QuickStart"
+		case "Turn OFF":
+			return "OFF"
+		case "Temperature":
+			return "Temp"
+		}
+		return ""
+	})
+	registry.RegisterReplyText("quickStart", func(ui *tbotworkflow.UserInputs) string {
+		return fmt.Sprintf("Starting AC <b>%s</b> with Temp 27 C at Medium fan speed", ui.Data["ACName"])
+	})
+	registry.RegisterReplyText("turnOff", func(ui *tbotworkflow.UserInputs) string {
+		return fmt.Sprintf("Turning OFF AC <b>%s</b>", ui.Data["ACName"])
+	})
+	registry.RegisterReplyText("acStart", func(ui *tbotworkflow.UserInputs) string {
+		return fmt.Sprintf("Starting AC <b>%s</b> with Temp %s and Fan Speed %s",
+			ui.Data["ACName"], ui.Data["ACTemp"], ui.Data["ACFanSpeed"])
+	})
+
+	return registry
+}
+
+func main() {
+	// Create your telegram Bot API client
+	botAPI, err := tgbotapi.NewBotAPI(botToken)
+	if err != nil {
+		log.Fatalf("Failed creating BotAPI. Error: %v", err)
+	}
+
+	// Load the workflows from the JSON document
+	f, err := os.Open("workflows.json")
+	if err != nil {
+		log.Fatalf("Failed opening workflows. Error: %v", err)
+	}
+	wfs, err := tbotworkflow.LoadWorkflows(f, getFuncRegistry())
+	f.Close()
+	if err != nil {
+		log.Fatalf("Failed loading workflows. Error: %v", err)
+	}
+
+	// Create new Workflow Controller and a Runner receiving the Bot updates
+	wfc := tbotworkflow.NewWorkflowController("WFC")
+	runner := tbotworkflow.NewRunner(botAPI, wfc)
+
+	// Add all the loaded workflows
+	for _, wf := range wfs {
+		err := runner.AddWorkflow(wf, func(userInputs *tbotworkflow.UserInputs) {
+			log.Printf("UID: %d, Command: %s, Params: %v", userInputs.UID, userInputs.Command, userInputs.Data)
+		})
+		if err != nil {
+			log.Fatalf("Failed adding workflow. Error: %v", err)
+		}
+	}
+
+	// Process the Telegram Bot Updates until the bot is stopped with Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := runner.Run(ctx); err != nil {
+		log.Fatalf("Failed running bot. Error: %v", err)
+	}
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/mail"
+	"os"
+	"os/signal"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	tbotworkflow "github.com/hbbtekademy/tbotworkflow"
@@ -77,32 +80,29 @@ func main() {
 		log.Fatalf("Failed creating BotAPI. Error: %v", err)
 	}
 
-	// Subscribe to the Bot updates
-	u := tgbotapi.NewUpdate(-1)
-	u.Timeout = 60
-	updates := botAPI.GetUpdatesChan(u)
-
 	// Create a TBotWorkflow
 	wf := getSeqWorkflow()
 
-	// Create new Workflow Controller
+	// Create new Workflow Controller and a Runner receiving the Bot updates
 	wfc := tbotworkflow.NewWorkflowController("WFC")
-	// Add the Workflow to the controller. Any number of workflows can be added to a Workflow Controller.
-	wfc.AddWorkflow(wf)
-
-	// Process the Telegram Bot Updates
-	for update := range updates {
-		// Do any pre-processing before invoking the workflows here.
-
-		// Execute the workflow
-		userInputs, done := wfc.Execute(update.Message, botAPI.Send)
-		if !done {
-			continue
-		}
+	runner := tbotworkflow.NewRunner(botAPI, wfc)
 
+	// Add the Workflow with the function handling the user inputs once a user completes the workflow.
+	// Any number of workflows can be added. The workflow steps are validated when the workflow is added.
+	err = runner.AddWorkflow(wf, func(userInputs *tbotworkflow.UserInputs) {
 		log.Printf("UserID: %d, Command: %s, Data: %v", userInputs.UID, userInputs.Command, userInputs.Data)
 
 		// Handle the user inputs as required.
+	})
+	if err != nil {
+		log.Fatalf("Failed adding workflow. Error: %v", err)
+	}
+
+	// Process the Telegram Bot Updates until the bot is stopped with Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := runner.Run(ctx); err != nil {
+		log.Fatalf("Failed running bot. Error: %v", err)
 	}
 }
 
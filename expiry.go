@@ -0,0 +1,101 @@
+package tbotworkflow
+
+import (
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func (t *workflowTracker) expired(now time.Time) bool {
+	return !t.expiresAt.IsZero() && !now.Before(t.expiresAt)
+}
+
+// StartJanitor starts a background goroutine that discards the sessions
+// which have been inactive past their IdleTimeout every interval.
+// The ExpiredReplyText of the workflow is sent to the user using the sendFunc
+// and the OnExpire function of the controller is called for each discarded session.
+// Call the returned function to stop the janitor.
+func (w *TBotWorkflowController) StartJanitor(interval time.Duration,
+	sendFunc func(c tgbotapi.Chattable) (tgbotapi.Message, error)) func() {
+	if w.now == nil {
+		w.now = time.Now
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer close(stopped)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.ExpireSessions(sendFunc)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// ExpireSessions discards all the sessions which have been inactive past their IdleTimeout.
+// This is what the janitor started by StartJanitor runs at each interval.
+func (w *TBotWorkflowController) ExpireSessions(sendFunc func(c tgbotapi.Chattable) (tgbotapi.Message, error)) {
+	if w.sessionStore == nil {
+		return
+	}
+	if w.now == nil {
+		w.now = time.Now
+	}
+
+	sessions, err := w.sessionStore.List()
+	if err != nil {
+		w.Logger.Printf("Failed listing sessions. Error: %v", err)
+		return
+	}
+
+	for _, session := range sessions {
+		if session.Expired(w.now()) {
+			w.expireLocked(session.Key, sendFunc)
+		}
+	}
+}
+
+// expireLocked expires the session for the key unless a message of the user
+// processed since the sessions were listed kept it alive.
+func (w *TBotWorkflowController) expireLocked(key SessionKey,
+	sendFunc func(c tgbotapi.Chattable) (tgbotapi.Message, error)) {
+	unlock := w.chatLocks.lock(key.ChatID)
+	defer unlock()
+
+	session, found, err := w.sessionStore.Get(key)
+	if err != nil {
+		w.Logger.Printf("Failed loading session. Error: %v", err)
+		return
+	}
+	if found && session.Expired(w.now()) {
+		w.expire(session, &sender{sendFunc: sendFunc, logger: w.Logger})
+	}
+}
+
+// expire discards the session and lets the user know their progress was reset.
+func (w *TBotWorkflowController) expire(session *Session, s *sender) {
+	w.Logger.Printf("Session for User: %d in Workflow: %s expired", session.UserInputs.UID, session.WorkflowName)
+	s.fail(w.deleteSession(session.Key))
+
+	if wf, found := w.workflows[session.Command]; found && wf.ExpiredReplyText != "" {
+		reply := tgbotapi.NewMessage(session.ChatID, w.translate(session.UserInputs.Language, wf.ExpiredReplyText))
+		reply.ParseMode = w.parseMode
+		reply.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{RemoveKeyboard: true, Selective: true}
+		s.send(reply)
+	}
+
+	if w.OnExpire != nil {
+		w.OnExpire(&session.UserInputs)
+	}
+}
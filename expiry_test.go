@@ -0,0 +1,89 @@
+package tbotworkflow
+
+import (
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestExpireSessions(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	now := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+
+	wfc := NewWorkflowController("WFC")
+	wfc.now = func() time.Time { return now }
+	var expiredInputs *UserInputs
+	wfc.OnExpire = func(ui *UserInputs) {
+		expiredInputs = ui
+	}
+
+	seqWF := newSeqWorkflow("CMD1")
+	seqWF.IdleTimeout = 10 * time.Minute
+	seqWF.ExpiredReplyText = "Form reset due to inactivity"
+	mustAddWorkflow(t, wfc, &seqWF)
+
+	botInteractions := getSeqBotInteractions()
+	wfc.Execute(&botInteractions[0].botMsg, mockSendFunc)
+	wfc.Execute(&botInteractions[1].botMsg, mockSendFunc)
+
+	now = now.Add(9 * time.Minute)
+	wfc.ExpireSessions(mockSendFunc)
+	if expiredInputs != nil {
+		t.Fatal("Session expired before its IdleTimeout")
+	}
+
+	now = now.Add(time.Minute)
+	sentCount := len(sentMsgs)
+	wfc.ExpireSessions(mockSendFunc)
+	if expiredInputs == nil {
+		t.Fatal("Expected session to expire but OnExpire was not called")
+	}
+	if expiredInputs.Data["K1"] != "Step1Option1" {
+		t.Errorf("Expected K1: Step1Option1 in expired inputs but got %s", expiredInputs.Data["K1"])
+	}
+	if len(sentMsgs) != sentCount+1 || sentMsgs[sentCount].Text != "Form reset due to inactivity" {
+		t.Errorf("Expected expiry message to be sent. Sent messages: %v", sentMsgs[sentCount:])
+	}
+
+	userInput, done := wfc.Execute(&botInteractions[2].botMsg, mockSendFunc)
+	if done || userInput != nil {
+		t.Error("Workflow completed but session should have expired")
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestStepIdleTimeoutOnExecute(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	now := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+
+	wfc := NewWorkflowController("WFC")
+	wfc.now = func() time.Time { return now }
+
+	seqWF := newSeqWorkflow("CMD1")
+	seqWF.ExpiredReplyText = "Form reset due to inactivity"
+	seqWF.RootStep.IdleTimeout = time.Minute
+	mustAddWorkflow(t, wfc, &seqWF)
+
+	botInteractions := getSeqBotInteractions()
+	wfc.Execute(&botInteractions[0].botMsg, mockSendFunc)
+
+	now = now.Add(2 * time.Minute)
+	sentCount := len(sentMsgs)
+	userInput, done := wfc.Execute(&botInteractions[1].botMsg, mockSendFunc)
+	if done || userInput != nil {
+		t.Error("Workflow completed but session should have expired")
+	}
+	if len(sentMsgs) != sentCount+1 || sentMsgs[sentCount].Text != "Form reset due to inactivity" {
+		t.Errorf("Expected only the expiry message to be sent. Sent messages: %v", sentMsgs[sentCount:])
+	}
+
+	// Starting the workflow again works as usual.
+	for _, bi := range botInteractions {
+		userInput, done = wfc.Execute(&bi.botMsg, mockSendFunc)
+	}
+	if !done || userInput == nil {
+		t.Error("Expected restarted workflow to complete")
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
@@ -0,0 +1,171 @@
+package tbotworkflow
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Formats of the diagrams written by WriteGraphs.
+const (
+	// GraphDOT writes a Graphviz DOT digraph for each workflow.
+	GraphDOT string = "dot"
+	// GraphMermaid writes a Mermaid flowchart for each workflow, separated by empty lines.
+	GraphMermaid string = "mermaid"
+	// GraphMarkdown writes a Markdown section with a Mermaid diagram for each workflow.
+	GraphMarkdown string = "markdown"
+)
+
+// DOT returns the steps of the workflow as a Graphviz DOT digraph.
+// Steps are labelled with their Name and Key, ConditionalNext edges with
+// the ConditionFunc output and the last steps of the workflow are highlighted.
+func (wf *TBotWorkflow) DOT() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", dotQuote(wf.Name))
+	fmt.Fprintf(&b, "\tlabel=%s;\n", dotQuote(wf.graphTitle()))
+	b.WriteString("\tnode [shape=box, style=rounded];\n")
+
+	steps := wf.steps()
+	nodes := graphNodes(steps)
+	for _, step := range steps {
+		attrs := fmt.Sprintf("label=%s", dotQuote(graphLabel(step, "\n")))
+		if step.isLastStep() {
+			attrs += ", style=\"rounded,filled\", fillcolor=lightgrey, peripheries=2"
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", nodes[step], attrs)
+	}
+	for _, step := range steps {
+		if !step.hasCondition() {
+			if step.Next != nil {
+				fmt.Fprintf(&b, "\t%s -> %s;\n", nodes[step], nodes[step.Next])
+			}
+			continue
+		}
+		for _, cond := range step.conditions() {
+			if next := step.ConditionalNext[cond]; next != nil {
+				fmt.Fprintf(&b, "\t%s -> %s [label=%s];\n", nodes[step], nodes[next], dotQuote(cond))
+			}
+		}
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// Mermaid returns the steps of the workflow as a Mermaid flowchart.
+// Steps are labelled with their Name and Key, ConditionalNext edges with
+// the ConditionFunc output and the last steps of the workflow are highlighted.
+func (wf *TBotWorkflow) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+
+	steps := wf.steps()
+	nodes := graphNodes(steps)
+	lastSteps := []string{}
+	for _, step := range steps {
+		label := mermaidQuote(graphLabel(step, "<br/>"))
+		if step.isLastStep() {
+			fmt.Fprintf(&b, "\t%s([%s])\n", nodes[step], label)
+			lastSteps = append(lastSteps, nodes[step])
+		} else {
+			fmt.Fprintf(&b, "\t%s[%s]\n", nodes[step], label)
+		}
+	}
+	for _, step := range steps {
+		if !step.hasCondition() {
+			if step.Next != nil {
+				fmt.Fprintf(&b, "\t%s --> %s\n", nodes[step], nodes[step.Next])
+			}
+			continue
+		}
+		for _, cond := range step.conditions() {
+			if next := step.ConditionalNext[cond]; next != nil {
+				fmt.Fprintf(&b, "\t%s -->|%s| %s\n", nodes[step], mermaidQuote(cond), nodes[next])
+			}
+		}
+	}
+	if len(lastSteps) > 0 {
+		b.WriteString("\tclassDef last fill:#d3d3d3,stroke:#333,stroke-width:2px\n")
+		fmt.Fprintf(&b, "\tclass %s last\n", strings.Join(lastSteps, ","))
+	}
+
+	return b.String()
+}
+
+// Workflows returns all the workflows registered on the controller sorted by Command.
+func (w *TBotWorkflowController) Workflows() []*TBotWorkflow {
+	wfs := make([]*TBotWorkflow, 0, len(w.workflows))
+	for _, wf := range w.workflows {
+		wfs = append(wfs, wf)
+	}
+	sort.Slice(wfs, func(i, j int) bool {
+		return wfs[i].Command < wfs[j].Command
+	})
+	return wfs
+}
+
+// WriteGraphs writes the diagrams of all the workflows registered on the controller, sorted by Command,
+// in one of the formats GraphDOT, GraphMermaid or GraphMarkdown.
+func (w *TBotWorkflowController) WriteGraphs(out io.Writer, format string) error {
+	if format != GraphDOT && format != GraphMermaid && format != GraphMarkdown {
+		return fmt.Errorf("unknown graph format %q", format)
+	}
+	for i, wf := range w.Workflows() {
+		var err error
+		switch format {
+		case GraphDOT:
+			_, err = io.WriteString(out, wf.DOT())
+		case GraphMermaid:
+			if i > 0 {
+				if _, err = io.WriteString(out, "\n"); err != nil {
+					return err
+				}
+			}
+			_, err = io.WriteString(out, wf.Mermaid())
+		case GraphMarkdown:
+			_, err = fmt.Fprintf(out, "## %s\n\n```mermaid\n%s```\n\n", wf.graphTitle(), wf.Mermaid())
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (wf *TBotWorkflow) graphTitle() string {
+	return fmt.Sprintf("%s (/%s)", wf.Name, strings.ToLower(wf.Command))
+}
+
+// graphNodes assigns each step a node ID in the order of the steps.
+func graphNodes(steps []*TBotWorkflowStep) map[*TBotWorkflowStep]string {
+	nodes := make(map[*TBotWorkflowStep]string, len(steps))
+	for i, step := range steps {
+		nodes[step] = fmt.Sprintf("s%d", i)
+	}
+	return nodes
+}
+
+func graphLabel(step *TBotWorkflowStep, lineBreak string) string {
+	label := step.Name
+	if step.Key != "" {
+		label += lineBreak + "Key: " + step.Key
+	}
+	if step.SubWorkflow != "" {
+		label += lineBreak + "SubWorkflow: " + step.SubWorkflow
+	}
+	return label
+}
+
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
+
+func mermaidQuote(s string) string {
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	s = strings.ReplaceAll(s, "|", "#124;")
+	return `"` + s + `"`
+}
@@ -0,0 +1,76 @@
+package tbotworkflow
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDOT(t *testing.T) {
+	condWF := newCondWorkflow("CMD2")
+	dot := condWF.DOT()
+
+	expectedLines := []string{
+		`digraph "ConditionalWF" {`,
+		`s0 [label="Step1\nKey: K1"];`,
+		`s1 -> s2 [label="C1"];`,
+		`s1 -> s3 [label="C2"];`,
+		`s4 [label="Step4", style="rounded,filled", fillcolor=lightgrey, peripheries=2];`,
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(dot, line) {
+			t.Errorf("Expected DOT to contain %s but got\n%s", line, dot)
+		}
+	}
+}
+
+func TestMermaid(t *testing.T) {
+	condWF := newCondWorkflow("CMD2")
+	mermaid := condWF.Mermaid()
+
+	expectedLines := []string{
+		"flowchart TD",
+		`s0["Step1<br/>Key: K1"]`,
+		`s1 -->|"C1"| s2`,
+		`s4(["Step4"])`,
+		"class s4 last",
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(mermaid, line) {
+			t.Errorf("Expected Mermaid to contain %s but got\n%s", line, mermaid)
+		}
+	}
+}
+
+func TestControllerWorkflows(t *testing.T) {
+	wfc := NewWorkflowController("WFC")
+	condWF := newCondWorkflow("CMD2")
+	seqWF := newSeqWorkflow("CMD1")
+	mustAddWorkflow(t, wfc, &condWF)
+	mustAddWorkflow(t, wfc, &seqWF)
+
+	wfs := wfc.Workflows()
+	if len(wfs) != 2 || wfs[0].Command != "CMD1" || wfs[1].Command != "CMD2" {
+		t.Errorf("Expected workflows for CMD1 and CMD2 but got %v", wfs)
+	}
+}
+
+func TestWriteGraphs(t *testing.T) {
+	wfc := NewWorkflowController("WFC")
+	condWF := newCondWorkflow("CMD2")
+	seqWF := newSeqWorkflow("CMD1")
+	mustAddWorkflow(t, wfc, &condWF)
+	mustAddWorkflow(t, wfc, &seqWF)
+
+	var b strings.Builder
+	if err := wfc.WriteGraphs(&b, GraphDOT); err != nil || b.String() != seqWF.DOT()+condWF.DOT() {
+		t.Errorf("Expected the DOT digraphs sorted by Command but got %v\n%s", err, b.String())
+	}
+	b.Reset()
+	if err := wfc.WriteGraphs(&b, GraphMarkdown); err != nil ||
+		!strings.HasPrefix(b.String(), "## "+seqWF.graphTitle()+"\n\n```mermaid\n"+seqWF.Mermaid()+"```\n\n") {
+		t.Errorf("Expected a Markdown section for each workflow but got %v\n%s", err, b.String())
+	}
+	if err := wfc.WriteGraphs(&b, "svg"); err == nil {
+		t.Error("Expected error for an unknown format")
+	}
+}
@@ -0,0 +1,60 @@
+package tbotworkflow
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HookFunc is called on the lifecycle events of a workflow with the user inputs captured so far,
+// the step the event relates to and the message from the user that triggered the event.
+// Hooks are notifications which cannot abort the message, so unlike the context aware functions of the steps
+// they do not receive the context passed to ExecuteContext. Work bound to a context should not be done in hooks.
+type HookFunc func(ui *UserInputs, step *TBotWorkflowStep, msg *tgbotapi.Message)
+
+// WorkflowHooks are the functions called on the lifecycle events of a workflow.
+// Hooks can be set on the TBotWorkflow and globally on the TBotWorkflowController.
+// Step hooks are called first, followed by the workflow hooks and the controller hooks.
+type WorkflowHooks struct {
+	// Called when the user starts the workflow with its Command. step is the RootStep.
+	OnStart HookFunc
+	// Called when the user enters a step, before the step's reply is sent.
+	OnStepEnter HookFunc
+	// Called when the user leaves a step, either with a valid input or the back button.
+	OnStepExit HookFunc
+	// Called when the workflow ends. step is the last step of the workflow.
+	OnComplete HookFunc
+	// Called when the user presses the cancel button. step is the step the user cancelled at.
+	OnCancel HookFunc
+}
+
+func callHooks(ui *UserInputs, step *TBotWorkflowStep, msg *tgbotapi.Message, hooks ...HookFunc) {
+	for _, hook := range hooks {
+		if hook != nil {
+			hook(ui, step, msg)
+		}
+	}
+}
+
+func (w *TBotWorkflowController) onStart(t *workflowTracker, msg *tgbotapi.Message) {
+	w.incCounter(MetricStarts, MetricLabels{Workflow: t.wf.Name})
+	callHooks(&t.userInputs, t.CurrentStep, msg, t.wf.Hooks.OnStart, w.Hooks.OnStart)
+}
+
+func (w *TBotWorkflowController) onStepEnter(t *workflowTracker, msg *tgbotapi.Message) {
+	w.stepEntered(t)
+	callHooks(&t.userInputs, t.CurrentStep, msg, t.CurrentStep.OnEnter, t.current().Hooks.OnStepEnter, w.Hooks.OnStepEnter)
+}
+
+func (w *TBotWorkflowController) onStepExit(t *workflowTracker, msg *tgbotapi.Message) {
+	w.observeDwell(t)
+	callHooks(&t.userInputs, t.CurrentStep, msg, t.CurrentStep.OnExit, t.current().Hooks.OnStepExit, w.Hooks.OnStepExit)
+}
+
+func (w *TBotWorkflowController) onComplete(t *workflowTracker, msg *tgbotapi.Message) {
+	w.incCounter(MetricCompletions, MetricLabels{Workflow: t.wf.Name})
+	callHooks(&t.userInputs, t.CurrentStep, msg, t.wf.Hooks.OnComplete, w.Hooks.OnComplete)
+}
+
+func (w *TBotWorkflowController) onCancel(t *workflowTracker, msg *tgbotapi.Message) {
+	w.incCounter(MetricCancellations, t.stepLabels())
+	callHooks(&t.userInputs, t.CurrentStep, msg, t.wf.Hooks.OnCancel, w.Hooks.OnCancel)
+}
@@ -0,0 +1,100 @@
+package tbotworkflow
+
+import (
+	"reflect"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestHooks(t *testing.T) {
+	var events []string
+	record := func(event string) HookFunc {
+		return func(ui *UserInputs, step *TBotWorkflowStep, msg *tgbotapi.Message) {
+			events = append(events, event+":"+step.Name+":"+msg.Text)
+		}
+	}
+
+	wfc := NewWorkflowController("WFC")
+	wfc.Hooks = WorkflowHooks{
+		OnStart:     record("ctrl-start"),
+		OnStepEnter: record("ctrl-enter"),
+		OnComplete:  record("ctrl-complete"),
+		OnCancel:    record("ctrl-cancel"),
+	}
+	seqWF := newSeqWorkflow("CMD1")
+	seqWF.BackButtonConfig = NewBackButtonConfig("BACK")
+	seqWF.Hooks = WorkflowHooks{
+		OnStart:     record("wf-start"),
+		OnStepEnter: record("wf-enter"),
+		OnStepExit:  record("wf-exit"),
+		OnComplete:  record("wf-complete"),
+		OnCancel:    record("wf-cancel"),
+	}
+	seqWF.RootStep.OnEnter = record("step-enter")
+	seqWF.RootStep.OnExit = record("step-exit")
+	mustAddWorkflow(t, wfc, &seqWF)
+
+	tests := []struct {
+		text           string
+		expectedEvents []string
+	}{
+		{text: "/CMD1", expectedEvents: []string{"wf-start:Step1:/CMD1", "ctrl-start:Step1:/CMD1",
+			"step-enter:Step1:/CMD1", "wf-enter:Step1:/CMD1", "ctrl-enter:Step1:/CMD1"}},
+		{text: "Step9Option9", expectedEvents: nil},
+		{text: "Step1Option1", expectedEvents: []string{"step-exit:Step1:Step1Option1", "wf-exit:Step1:Step1Option1",
+			"wf-enter:Step2:Step1Option1", "ctrl-enter:Step2:Step1Option1"}},
+		{text: "BACK", expectedEvents: []string{"wf-exit:Step2:BACK",
+			"step-enter:Step1:BACK", "wf-enter:Step1:BACK", "ctrl-enter:Step1:BACK"}},
+		{text: "RESET", expectedEvents: []string{"wf-cancel:Step1:RESET", "ctrl-cancel:Step1:RESET"}},
+		{text: "/CMD1", expectedEvents: []string{"wf-start:Step1:/CMD1", "ctrl-start:Step1:/CMD1",
+			"step-enter:Step1:/CMD1", "wf-enter:Step1:/CMD1", "ctrl-enter:Step1:/CMD1"}},
+		{text: "Step1Option2", expectedEvents: []string{"step-exit:Step1:Step1Option2", "wf-exit:Step1:Step1Option2",
+			"wf-enter:Step2:Step1Option2", "ctrl-enter:Step2:Step1Option2"}},
+		{text: "Step2Option3", expectedEvents: []string{"wf-exit:Step2:Step2Option3",
+			"wf-enter:Step3:Step2Option3", "ctrl-enter:Step3:Step2Option3",
+			"wf-complete:Step3:Step2Option3", "ctrl-complete:Step3:Step2Option3"}},
+	}
+
+	for i, tc := range tests {
+		events = nil
+		msg := mockBotMessage(1, tc.text)
+		if tc.text[0] == '/' {
+			msg = mockBotCommand(1, tc.text)
+		}
+		wfc.Execute(&msg, mockSendFunc)
+		if !reflect.DeepEqual(events, tc.expectedEvents) {
+			t.Errorf("Step %d/Input:%s Expected hooks %v but got %v", i+1, tc.text, tc.expectedEvents, events)
+		}
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestHooksUserInputs(t *testing.T) {
+	var completed *UserInputs
+	wfc := NewWorkflowController("WFC")
+	seqWF := newSeqWorkflow("CMD1")
+	seqWF.Hooks.OnStepExit = func(ui *UserInputs, step *TBotWorkflowStep, msg *tgbotapi.Message) {
+		if ui.Data[step.Key] != msg.Text {
+			t.Errorf("Expected input %s captured on exit of Step: %s but got %s", msg.Text, step.Name, ui.Data[step.Key])
+		}
+	}
+	seqWF.Hooks.OnComplete = func(ui *UserInputs, step *TBotWorkflowStep, msg *tgbotapi.Message) {
+		completed = ui
+	}
+	mustAddWorkflow(t, wfc, &seqWF)
+
+	var userInput *UserInputs
+	for _, text := range []string{"/CMD1", "Step1Option1", "Step2Option3"} {
+		msg := mockBotMessage(1, text)
+		if text[0] == '/' {
+			msg = mockBotCommand(1, text)
+		}
+		userInput, _ = wfc.Execute(&msg, mockSendFunc)
+	}
+
+	if completed == nil || completed != userInput {
+		t.Errorf("Expected OnComplete to receive the returned UserInputs but got %v", completed)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
@@ -0,0 +1,141 @@
+package tbotworkflow
+
+import (
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// MessageCatalog translates the texts sent to the users.
+// Texts are looked up by their source text as defined in the workflows,
+// e.g. the ReplyText of a step or the Text of a keyboard button.
+// The format strings of the texts generated by the library, e.g. "Invalid input %s. Please try again",
+// are looked up before the user input is filled in.
+type MessageCatalog interface {
+	// Translate returns the text in the given language.
+	// bool = false means there is no translation and the source text is sent.
+	Translate(lang string, text string) (string, bool)
+}
+
+// MapCatalog is a MessageCatalog backed by a map of languages to a map of source texts to translated texts.
+// Languages are IETF language tags as sent by Telegram, e.g. "de" or "pt-br".
+// Translations for a regional language fall back to the base language, e.g. "pt-br" to "pt".
+type MapCatalog map[string]map[string]string
+
+// Translate returns the text in the given language.
+func (c MapCatalog) Translate(lang string, text string) (string, bool) {
+	lang = strings.ToLower(lang)
+	if translated, found := c[lang][text]; found {
+		return translated, true
+	}
+	if i := strings.IndexAny(lang, "-_"); i > 0 {
+		translated, found := c[lang[:i]][text]
+		return translated, found
+	}
+	return "", false
+}
+
+// SetUserLanguage overrides the language of the user given by the Telegram client.
+// Set to empty string to remove the override. Overrides are not persisted in the SessionStore.
+func (w *TBotWorkflowController) SetUserLanguage(uid int64, lang string) {
+	w.languagesMu.Lock()
+	defer w.languagesMu.Unlock()
+	if lang == "" {
+		delete(w.languages, uid)
+		return
+	}
+	if w.languages == nil {
+		w.languages = make(map[int64]string)
+	}
+	w.languages[uid] = lang
+}
+
+// language returns the language of the user who sent the message:
+// the language set with SetUserLanguage, the language of the Telegram client or the DefaultLanguage.
+func (w *TBotWorkflowController) language(msg *tgbotapi.Message) string {
+	w.languagesMu.Lock()
+	lang, found := w.languages[msg.From.ID]
+	w.languagesMu.Unlock()
+	if found {
+		return lang
+	}
+	if msg.From.LanguageCode != "" {
+		return msg.From.LanguageCode
+	}
+	return w.DefaultLanguage
+}
+
+// translate returns the text in the given language, or the text itself if there is no translation.
+func (w *TBotWorkflowController) translate(lang string, text string) string {
+	if w.Catalog == nil || text == "" {
+		return text
+	}
+	if translated, found := w.Catalog.Translate(lang, text); found {
+		return translated
+	}
+	return text
+}
+
+// translateKB returns a copy of the keyboard with the button texts in the given language.
+func (w *TBotWorkflowController) translateKB(lang string, kb *tgbotapi.ReplyKeyboardMarkup) *tgbotapi.ReplyKeyboardMarkup {
+	if w.Catalog == nil || kb == nil {
+		return kb
+	}
+	translated := *kb
+	translated.Keyboard = make([][]tgbotapi.KeyboardButton, len(kb.Keyboard))
+	for i, row := range kb.Keyboard {
+		translated.Keyboard[i] = make([]tgbotapi.KeyboardButton, len(row))
+		for j, button := range row {
+			button.Text = w.translate(lang, button.Text)
+			translated.Keyboard[i][j] = button
+		}
+	}
+	return &translated
+}
+
+// translateInlineKB returns a copy of the inline keyboard with the button texts in the given language.
+// The CallbackData of the buttons is not translated.
+func (w *TBotWorkflowController) translateInlineKB(lang string, kb *tgbotapi.InlineKeyboardMarkup) *tgbotapi.InlineKeyboardMarkup {
+	if w.Catalog == nil || kb == nil {
+		return kb
+	}
+	translated := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: make([][]tgbotapi.InlineKeyboardButton, len(kb.InlineKeyboard))}
+	for i, row := range kb.InlineKeyboard {
+		translated.InlineKeyboard[i] = make([]tgbotapi.InlineKeyboardButton, len(row))
+		for j, button := range row {
+			button.Text = w.translate(lang, button.Text)
+			translated.InlineKeyboard[i][j] = button
+		}
+	}
+	return &translated
+}
+
+// sourceMessage returns the message with the text of a translated button of the CurrentStep,
+// or of the cancel or back button, replaced by the source text of the button.
+// This way validation, the cancel and back buttons and the UserInputs work with the source texts.
+func (w *TBotWorkflowController) sourceMessage(msg *tgbotapi.Message, userWfTracker *workflowTracker, lang string) *tgbotapi.Message {
+	if w.Catalog == nil || msg.Text == "" {
+		return msg
+	}
+
+	labels := []string{w.getCancelBtnConfig(userWfTracker).cancelButtonText, w.getBackBtnConfig(userWfTracker).backButtonText}
+	if repeat := userWfTracker.CurrentStep.Repeat; repeat != nil {
+		labels = append(labels, repeat.doneButtonText)
+	}
+	if kb := userWfTracker.CurrentStep.KB; kb != nil {
+		for _, row := range kb.Keyboard {
+			for _, button := range row {
+				labels = append(labels, button.Text)
+			}
+		}
+	}
+
+	for _, label := range labels {
+		if label != "" && label != msg.Text && w.translate(lang, label) == msg.Text {
+			source := *msg
+			source.Text = label
+			return &source
+		}
+	}
+	return msg
+}
@@ -0,0 +1,138 @@
+package tbotworkflow
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var germanCatalog = MapCatalog{
+	"de": {
+		"Please select an option":            "Bitte eine Option wählen",
+		"Please select another option":       "Bitte eine weitere Option wählen",
+		"Step1Option1":                       "Schritt1Option1",
+		"RESET":                              "ZURÜCKSETZEN",
+		"Clearing input. Please start again": "Eingaben gelöscht. Bitte neu beginnen",
+		"Invalid input %s. Please try again": "Ungültige Eingabe %s. Bitte erneut versuchen",
+		"Message \"%s\" cannot be processed. Please select valid command.": "Nachricht \"%s\" kann nicht verarbeitet werden.",
+	},
+}
+
+func TestMapCatalog(t *testing.T) {
+	tests := []struct {
+		lang          string
+		text          string
+		expectedText  string
+		expectedFound bool
+	}{
+		{lang: "de", text: "RESET", expectedText: "ZURÜCKSETZEN", expectedFound: true},
+		{lang: "de-CH", text: "RESET", expectedText: "ZURÜCKSETZEN", expectedFound: true},
+		{lang: "DE", text: "RESET", expectedText: "ZURÜCKSETZEN", expectedFound: true},
+		{lang: "de", text: "Unknown", expectedFound: false},
+		{lang: "fr", text: "RESET", expectedFound: false},
+		{lang: "", text: "RESET", expectedFound: false},
+	}
+
+	for i, tc := range tests {
+		text, found := germanCatalog.Translate(tc.lang, tc.text)
+		if text != tc.expectedText || found != tc.expectedFound {
+			t.Errorf("Test %d Expected %s/%v but got %s/%v", i+1, tc.expectedText, tc.expectedFound, text, found)
+		}
+	}
+}
+
+func TestTranslatedWorkflow(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	sentChattables = []tgbotapi.Chattable{}
+	wfc := NewWorkflowController("WFC")
+	wfc.Catalog = germanCatalog
+	seqWF := newSeqWorkflow("CMD1")
+	mustAddWorkflow(t, wfc, &seqWF)
+
+	germanMsg := func(text string) *tgbotapi.Message {
+		msg := mockBotMessage(1, text)
+		if text[0] == '/' {
+			msg = mockBotCommand(1, text)
+		}
+		msg.From.LanguageCode = "de"
+		return &msg
+	}
+
+	wfc.Execute(germanMsg("/CMD1"), mockSendFunc)
+	reply := sentChattables[len(sentChattables)-1].(tgbotapi.MessageConfig)
+	if reply.Text != "Bitte eine Option wählen" {
+		t.Errorf("Expected translated reply text but got %s", reply.Text)
+	}
+	kb := reply.ReplyMarkup.(*tgbotapi.ReplyKeyboardMarkup)
+	if kb.Keyboard[0][0].Text != "Schritt1Option1" || kb.Keyboard[0][1].Text != "Step1Option2" || kb.Keyboard[2][0].Text != "ZURÜCKSETZEN" {
+		t.Errorf("Expected translated keyboard but got %v", kb.Keyboard)
+	}
+	if seqWF.RootStep.KB.Keyboard[0][0].Text != "Step1Option1" {
+		t.Error("Translating the keyboard changed the keyboard of the step")
+	}
+
+	wfc.Execute(germanMsg("Step9Option9"), mockSendFunc)
+	if invalidReply := sentMsgs[len(sentMsgs)-2].Text; invalidReply != "Ungültige Eingabe Step9Option9. Bitte erneut versuchen" {
+		t.Errorf("Expected translated invalid input reply but got %s", invalidReply)
+	}
+
+	// The translated button is captured with its source text.
+	wfc.Execute(germanMsg("Schritt1Option1"), mockSendFunc)
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Bitte eine weitere Option wählen" {
+		t.Errorf("Expected the translated text of step 2 but got %s", reply)
+	}
+
+	wfc.Execute(germanMsg("ZURÜCKSETZEN"), mockSendFunc)
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Eingaben gelöscht. Bitte neu beginnen" {
+		t.Errorf("Expected the translated cancel reply but got %s", reply)
+	}
+
+	wfc.Execute(germanMsg("/XYZ"), mockSendFunc)
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Nachricht \"XYZ\" kann nicht verarbeitet werden." {
+		t.Errorf("Expected the translated workflow not found reply but got %s", reply)
+	}
+
+	var userInput *UserInputs
+	for _, text := range []string{"/CMD1", "Schritt1Option1", "Step2Option3"} {
+		userInput, _ = wfc.Execute(germanMsg(text), mockSendFunc)
+	}
+	if userInput == nil || userInput.Data["K1"] != "Step1Option1" || userInput.Language != "de" {
+		t.Errorf("Expected source text of the button in german UserInputs but got %v", userInput)
+	}
+	sentMsgs = []tgbotapi.Message{}
+	sentChattables = []tgbotapi.Chattable{}
+}
+
+func TestUserLanguage(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	wfc.Catalog = germanCatalog
+	wfc.DefaultLanguage = "de"
+	seqWF := newSeqWorkflow("CMD1")
+	mustAddWorkflow(t, wfc, &seqWF)
+
+	// Telegram client without language uses the DefaultLanguage.
+	msg := mockBotCommand(1, "/CMD1")
+	wfc.Execute(&msg, mockSendFunc)
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Bitte eine Option wählen" {
+		t.Errorf("Expected the DefaultLanguage but got %s", reply)
+	}
+
+	// The override takes priority over the language of the Telegram client.
+	wfc.SetUserLanguage(1234, "en")
+	msg = mockBotCommand(1, "/CMD1")
+	msg.From.LanguageCode = "de"
+	wfc.Execute(&msg, mockSendFunc)
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Please select an option" {
+		t.Errorf("Expected the language override but got %s", reply)
+	}
+
+	wfc.SetUserLanguage(1234, "")
+	msg = mockBotCommand(1, "/CMD1")
+	msg.From.LanguageCode = "de"
+	wfc.Execute(&msg, mockSendFunc)
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Bitte eine Option wählen" {
+		t.Errorf("Expected the language of the Telegram client but got %s", reply)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
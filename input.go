@@ -0,0 +1,162 @@
+package tbotworkflow
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// Default Text sent to the user for an input of the wrong kind.
+	defaultWrongInputKindReplyText string = "Invalid input. Please send a %s"
+)
+
+// InputKind is the kind of message a TBotWorkflowStep accepts as user input.
+type InputKind string
+
+const (
+	// InputText accepts text messages. This is the default for steps without an InputKind.
+	InputText InputKind = "text"
+	// InputPhoto accepts photos. The largest size of the photo is captured.
+	InputPhoto InputKind = "photo"
+	// InputDocument accepts files sent as documents.
+	InputDocument InputKind = "document"
+	// InputLocation accepts shared locations, e.g. from a RequestLocation keyboard button.
+	InputLocation InputKind = "location"
+	// InputContact accepts shared contacts, e.g. from a RequestContact keyboard button.
+	InputContact InputKind = "contact"
+	// InputVoice accepts voice messages.
+	InputVoice InputKind = "voice"
+	// InputVideo accepts videos.
+	InputVideo InputKind = "video"
+)
+
+// InputValue is the structured user input captured at a step.
+// Only the fields relevant to the Kind of the input are set.
+type InputValue struct {
+	// Kind of the message the input was captured from.
+	Kind InputKind
+	// Text of the message, or the caption of the photo, document, voice or video.
+	Text string
+	// Telegram file ID of the photo, document, voice or video. Can be used to download or resend the file.
+	FileID string
+	// Telegram unique file ID of the photo, document, voice or video. Stable across bots.
+	FileUniqueID string
+	// Name of the document or video file.
+	FileName string
+	// MIME type of the document, voice or video file.
+	MimeType string
+	// Size of the file in bytes.
+	FileSize int
+	// Coordinates of the shared location.
+	Latitude  float64
+	Longitude float64
+	// Phone number and name of the shared contact.
+	PhoneNumber string
+	FirstName   string
+	LastName    string
+	// Telegram User ID of the shared contact, if the contact is a Telegram user.
+	ContactUID int64
+	// ValueType of the step the input was captured at. Empty for steps without a ValueType.
+	Type ValueType
+	// Parsed value of ValueInt, ValueFloat, ValueBool and ValueTime inputs.
+	Int   int64
+	Float float64
+	Bool  bool
+	Time  time.Time
+	// Normalized value of ValueEnum, ValueEmail and ValuePhone inputs,
+	// i.e. the matching enum value, the address without the display name or the number without separators.
+	Normalized string
+}
+
+// String returns the text representation of the input stored in UserInputs.Data:
+// the Normalized value if set, the text for text inputs, the file ID for files,
+// "latitude,longitude" for locations and the phone number for contacts.
+func (v InputValue) String() string {
+	if v.Normalized != "" {
+		return v.Normalized
+	}
+	switch v.Kind {
+	case InputPhoto, InputDocument, InputVoice, InputVideo:
+		return v.FileID
+	case InputLocation:
+		return strconv.FormatFloat(v.Latitude, 'f', -1, 64) + "," + strconv.FormatFloat(v.Longitude, 'f', -1, 64)
+	case InputContact:
+		return v.PhoneNumber
+	}
+	return v.Text
+}
+
+func (s *TBotWorkflowStep) inputKind() InputKind {
+	if s.InputKind == "" {
+		return InputText
+	}
+	return s.InputKind
+}
+
+// acceptsInputKind tells if the step accepts a message of the kind.
+// ValuePhone steps accept a phone number as text or a shared contact, whichever of the two is their InputKind.
+func (s *TBotWorkflowStep) acceptsInputKind(kind InputKind) bool {
+	if s.valueType() == ValuePhone && (kind == InputText || kind == InputContact) {
+		return s.inputKind() == InputText || s.inputKind() == InputContact
+	}
+	return kind == s.inputKind()
+}
+
+// messageInputKind returns the kind of input carried by the message.
+// Messages without any of the supported media are text messages.
+func messageInputKind(msg *tgbotapi.Message) InputKind {
+	switch {
+	case len(msg.Photo) > 0:
+		return InputPhoto
+	case msg.Document != nil:
+		return InputDocument
+	case msg.Location != nil:
+		return InputLocation
+	case msg.Contact != nil:
+		return InputContact
+	case msg.Voice != nil:
+		return InputVoice
+	case msg.Video != nil:
+		return InputVideo
+	}
+	return InputText
+}
+
+// newInputValue returns the structured input carried by the message.
+func newInputValue(msg *tgbotapi.Message) InputValue {
+	v := InputValue{Kind: messageInputKind(msg), Text: msg.Text}
+	switch v.Kind {
+	case InputPhoto:
+		photo := msg.Photo[len(msg.Photo)-1]
+		v.Text = msg.Caption
+		v.FileID, v.FileUniqueID, v.FileSize = photo.FileID, photo.FileUniqueID, photo.FileSize
+	case InputDocument:
+		v.Text = msg.Caption
+		v.FileID, v.FileUniqueID, v.FileSize = msg.Document.FileID, msg.Document.FileUniqueID, msg.Document.FileSize
+		v.FileName, v.MimeType = msg.Document.FileName, msg.Document.MimeType
+	case InputLocation:
+		v.Latitude, v.Longitude = msg.Location.Latitude, msg.Location.Longitude
+	case InputContact:
+		v.PhoneNumber, v.FirstName, v.LastName = msg.Contact.PhoneNumber, msg.Contact.FirstName, msg.Contact.LastName
+		v.ContactUID = msg.Contact.UserID
+	case InputVoice:
+		v.Text = msg.Caption
+		v.FileID, v.FileUniqueID, v.FileSize = msg.Voice.FileID, msg.Voice.FileUniqueID, msg.Voice.FileSize
+		v.MimeType = msg.Voice.MimeType
+	case InputVideo:
+		v.Text = msg.Caption
+		v.FileID, v.FileUniqueID, v.FileSize = msg.Video.FileID, msg.Video.FileUniqueID, msg.Video.FileSize
+		v.FileName, v.MimeType = msg.Video.FileName, msg.Video.MimeType
+	}
+	return v
+}
+
+func (w *TBotWorkflowController) getWrongInputKindReplyText(step *TBotWorkflowStep, lang string) string {
+	if step.WrongInputKindReplyText != "" {
+		return w.translate(lang, step.WrongInputKindReplyText)
+	}
+	return fmt.Sprintf(w.translate(lang, defaultWrongInputKindReplyText), w.translate(lang, string(step.inputKind())))
+}
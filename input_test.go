@@ -0,0 +1,144 @@
+package tbotworkflow
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestMediaInputs(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	mediaWF := newMediaWorkflow("CMD1")
+	mustAddWorkflow(t, wfc, &mediaWF)
+
+	photoMsg := mockBotMessage(1, "")
+	photoMsg.Caption = "Lunch"
+	photoMsg.Photo = []tgbotapi.PhotoSize{
+		{FileID: "small", FileUniqueID: "u-small", Width: 90},
+		{FileID: "large", FileUniqueID: "u-large", Width: 1280, FileSize: 2048},
+	}
+	locationMsg := mockBotMessage(1, "")
+	locationMsg.Location = &tgbotapi.Location{Latitude: 52.52, Longitude: 13.405}
+	contactMsg := mockBotMessage(1, "")
+	contactMsg.Contact = &tgbotapi.Contact{PhoneNumber: "+4915112345678", FirstName: "Unit", UserID: 1234}
+	documentMsg := mockBotMessage(1, "")
+	documentMsg.Document = &tgbotapi.Document{FileID: "doc", FileName: "receipt.pdf", MimeType: "application/pdf"}
+	textMsg := mockBotMessage(1, "Some text")
+
+	tests := []struct {
+		msg            tgbotapi.Message
+		expectedReply  string
+		expectedResult Result
+	}{
+		{msg: mockBotCommand(1, "/CMD1"), expectedReply: "Please send a photo of the receipt", expectedResult: ResultInProgress},
+		{msg: textMsg, expectedReply: "Invalid input. Please send a photo", expectedResult: ResultValidationFailed},
+		{msg: documentMsg, expectedReply: "Invalid input. Please send a photo", expectedResult: ResultValidationFailed},
+		{msg: photoMsg, expectedReply: "Where are you?", expectedResult: ResultInProgress},
+		{msg: textMsg, expectedReply: "Please press the Share location button", expectedResult: ResultValidationFailed},
+		{msg: locationMsg, expectedReply: "Please share your phone number", expectedResult: ResultInProgress},
+		{msg: photoMsg, expectedReply: "Invalid input. Please send a contact", expectedResult: ResultValidationFailed},
+		{msg: contactMsg, expectedReply: "Any comments?", expectedResult: ResultInProgress},
+		{msg: locationMsg, expectedReply: "Invalid input. Please send a text", expectedResult: ResultValidationFailed},
+		{msg: textMsg, expectedReply: "Thank you", expectedResult: ResultCompleted},
+	}
+
+	var userInput *UserInputs
+	for i, tc := range tests {
+		msg := tc.msg
+		var result Result
+		userInput, result, _ = wfc.ExecuteE(&msg, mockSendFunc)
+		if result != tc.expectedResult {
+			t.Errorf("Step %d Expected result %v but got %v", i+1, tc.expectedResult, result)
+		}
+		// The step is repeated after the invalid input reply.
+		reply := sentMsgs[len(sentMsgs)-1].Text
+		if result == ResultValidationFailed {
+			reply = sentMsgs[len(sentMsgs)-2].Text
+		}
+		if reply != tc.expectedReply {
+			t.Errorf("Step %d Expected reply %s but got %s", i+1, tc.expectedReply, reply)
+		}
+	}
+
+	if userInput == nil {
+		t.Fatal("Expected workflow to complete")
+	}
+	expectedData := map[string]string{
+		"Receipt":  "large",
+		"Location": "52.52,13.405",
+		"Phone":    "+4915112345678",
+		"Comments": "Some text",
+	}
+	for k, v := range expectedData {
+		if userInput.Data[k] != v {
+			t.Errorf("Expected %s for Key: %s but got %s", v, k, userInput.Data[k])
+		}
+	}
+
+	receipt := userInput.Inputs["Receipt"]
+	if receipt.Kind != InputPhoto || receipt.FileUniqueID != "u-large" || receipt.FileSize != 2048 || receipt.Text != "Lunch" {
+		t.Errorf("Expected the largest photo with its caption but got %+v", receipt)
+	}
+	location := userInput.Inputs["Location"]
+	if location.Kind != InputLocation || location.Latitude != 52.52 || location.Longitude != 13.405 {
+		t.Errorf("Expected the shared location but got %+v", location)
+	}
+	phone := userInput.Inputs["Phone"]
+	if phone.Kind != InputContact || phone.FirstName != "Unit" || phone.ContactUID != 1234 {
+		t.Errorf("Expected the shared contact but got %+v", phone)
+	}
+	if comments := userInput.Inputs["Comments"]; comments.Kind != InputText || comments.Text != "Some text" {
+		t.Errorf("Expected the text input but got %+v", comments)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestMessageInputKind(t *testing.T) {
+	tests := []struct {
+		msg          tgbotapi.Message
+		expectedKind InputKind
+	}{
+		{msg: tgbotapi.Message{Text: "text"}, expectedKind: InputText},
+		{msg: tgbotapi.Message{Photo: []tgbotapi.PhotoSize{{FileID: "p"}}}, expectedKind: InputPhoto},
+		{msg: tgbotapi.Message{Document: &tgbotapi.Document{FileID: "d"}}, expectedKind: InputDocument},
+		{msg: tgbotapi.Message{Location: &tgbotapi.Location{}}, expectedKind: InputLocation},
+		{msg: tgbotapi.Message{Contact: &tgbotapi.Contact{}}, expectedKind: InputContact},
+		{msg: tgbotapi.Message{Voice: &tgbotapi.Voice{FileID: "v"}}, expectedKind: InputVoice},
+		{msg: tgbotapi.Message{Video: &tgbotapi.Video{FileID: "vi"}}, expectedKind: InputVideo},
+	}
+
+	for i, tc := range tests {
+		if kind := messageInputKind(&tc.msg); kind != tc.expectedKind {
+			t.Errorf("Test %d Expected %s but got %s", i+1, tc.expectedKind, kind)
+		}
+	}
+}
+
+func newMediaWorkflow(cmd string) TBotWorkflow {
+	step1 := NewWorkflowStep("Step1", "Receipt", "Please send a photo of the receipt", nil)
+	step1.InputKind = InputPhoto
+
+	locationKB := tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButtonLocation("Share location")),
+	)
+	step2 := NewWorkflowStep("Step2", "Location", "Where are you?", &locationKB)
+	step2.InputKind = InputLocation
+	step2.WrongInputKindReplyText = "Please press the Share location button"
+
+	contactKB := tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButtonContact("Share phone number")),
+	)
+	step3 := NewWorkflowStep("Step3", "Phone", "Please share your phone number", &contactKB)
+	step3.InputKind = InputContact
+
+	step4 := NewWorkflowStep("Step4", "Comments", "Any comments?", nil)
+	step5 := NewWorkflowStep("Step5", "", "Thank you", nil)
+
+	step1.Next = &step2
+	step2.Next = &step3
+	step3.Next = &step4
+	step4.Next = &step5
+
+	return NewWorkflow("WF", cmd, &step1)
+}
@@ -0,0 +1,377 @@
+package tbotworkflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// FuncRegistry holds the named Go functions that declarative workflow
+// documents can reference for validators, conditions and reply texts.
+type FuncRegistry struct {
+	validators map[string]func(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool)
+	conditions map[string]func(msg *tgbotapi.Message) string
+	replyTexts map[string]func(ui *UserInputs) string
+	stub       bool
+}
+
+// NewFuncRegistry returns a pointer to an empty FuncRegistry.
+func NewFuncRegistry() *FuncRegistry {
+	return &FuncRegistry{
+		validators: make(map[string]func(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool)),
+		conditions: make(map[string]func(msg *tgbotapi.Message) string),
+		replyTexts: make(map[string]func(ui *UserInputs) string),
+	}
+}
+
+// NewStubFuncRegistry returns a pointer to a FuncRegistry resolving the functions which are not registered with stubs,
+// e.g. to render the workflows of a document with DOT or Mermaid without the Go functions it references.
+// Stub validators accept all inputs, stub conditions return an empty string and stub reply texts are empty.
+func NewStubFuncRegistry() *FuncRegistry {
+	r := NewFuncRegistry()
+	r.stub = true
+	return r
+}
+
+// RegisterValidator registers a ValidateInputFunc that steps can reference by name with "validator".
+func (r *FuncRegistry) RegisterValidator(name string,
+	fn func(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool)) {
+	r.validators[name] = fn
+}
+
+// RegisterCondition registers a ConditionFunc that steps can reference by name with "condition".
+func (r *FuncRegistry) RegisterCondition(name string, fn func(msg *tgbotapi.Message) string) {
+	r.conditions[name] = fn
+}
+
+// RegisterReplyText registers a ReplyTextFunc that steps can reference by name with "replyTextFunc".
+func (r *FuncRegistry) RegisterReplyText(name string, fn func(ui *UserInputs) string) {
+	r.replyTexts[name] = fn
+}
+
+func (r *FuncRegistry) validator(name string) (func(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool), bool) {
+	if fn, found := r.validators[name]; found || !r.stub {
+		return fn, found
+	}
+	return func(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool) { return "", true }, true
+}
+
+func (r *FuncRegistry) condition(name string) (func(msg *tgbotapi.Message) string, bool) {
+	if fn, found := r.conditions[name]; found || !r.stub {
+		return fn, found
+	}
+	return func(msg *tgbotapi.Message) string { return "" }, true
+}
+
+func (r *FuncRegistry) replyText(name string) (func(ui *UserInputs) string, bool) {
+	if fn, found := r.replyTexts[name]; found || !r.stub {
+		return fn, found
+	}
+	return func(ui *UserInputs) string { return "" }, true
+}
+
+type workflowsDocument struct {
+	Workflows []workflowDefinition `json:"workflows"`
+}
+
+type workflowDefinition struct {
+	Name             string            `json:"name"`
+	Command          string            `json:"command"`
+	Root             string            `json:"root"`
+	CancelButton     *buttonDefinition `json:"cancelButton"`
+	BackButton       *buttonDefinition `json:"backButton"`
+	IdleTimeout      string            `json:"idleTimeout"`
+	ExpiredReplyText string            `json:"expiredReplyText"`
+	ChatScoped       bool              `json:"chatScoped"`
+	Steps            []stepDefinition  `json:"steps"`
+}
+
+type stepDefinition struct {
+	ID                  string                     `json:"id"`
+	Name                string                     `json:"name"`
+	Key                 string                     `json:"key"`
+	ReplyText           string                     `json:"replyText"`
+	ReplyTextFunc       string                     `json:"replyTextFunc"`
+	Keyboard            [][]string                 `json:"keyboard"`
+	InlineKeyboard      [][]inlineButtonDefinition `json:"inlineKeyboard"`
+	EditCallbackMessage bool                       `json:"editCallbackMessage"`
+	Next                string                     `json:"next"`
+	Condition           string                     `json:"condition"`
+	ConditionalNext     map[string]string          `json:"conditionalNext"`
+	SubWorkflow         string                     `json:"subWorkflow"`
+	Repeat              *repeatDefinition          `json:"repeat"`
+	Summary             *summaryDefinition         `json:"summary"`
+	Validator           string                     `json:"validator"`
+	Input               InputKind                  `json:"input"`
+	WrongInputReplyText string                     `json:"wrongInputReplyText"`
+	ValueType           ValueType                  `json:"valueType"`
+	TimeLayout          string                     `json:"timeLayout"`
+	EnumValues          []string                   `json:"enumValues"`
+	InvalidValueReply   string                     `json:"invalidValueReplyText"`
+	CancelButton        *buttonDefinition          `json:"cancelButton"`
+	BackButton          *buttonDefinition          `json:"backButton"`
+	IdleTimeout         string                     `json:"idleTimeout"`
+}
+
+type buttonDefinition struct {
+	Text  string `json:"text"`
+	Reply string `json:"reply"`
+}
+
+type repeatDefinition struct {
+	DoneButton string `json:"doneButton"`
+	Min        int    `json:"min"`
+	Max        int    `json:"max"`
+}
+
+type summaryDefinition struct {
+	ConfirmButton string `json:"confirmButton"`
+	EditButton    string `json:"editButton"`
+}
+
+type inlineButtonDefinition struct {
+	Text string `json:"text"`
+	Data string `json:"data"`
+}
+
+// LoadWorkflows builds the workflows defined in a JSON document.
+// Steps reference each other by ID (defaults to the step name) and
+// reference Go functions by the name they are registered with in the registry.
+// The registry can be nil if the document does not reference any functions.
+// Each workflow is checked with Validate before it is returned.
+//
+// Example document
+//
+//	{
+//	  "workflows": [{
+//	    "name": "WF1", "command": "subscribe",
+//	    "cancelButton": {"text": "Cancel", "reply": "Canceling registration."},
+//	    "idleTimeout": "30m",
+//	    "steps": [
+//	      {"name": "Step1", "key": "Email", "replyText": "Please enter your Email", "validator": "email", "next": "Step2"},
+//	      {"name": "Step2", "key": "Plan", "replyText": "Please select a Plan", "keyboard": [["Basic", "Pro"], ["Cancel"]],
+//	       "condition": "plan", "conditionalNext": {"basic": "Step3", "pro": "Step3"}},
+//	      {"name": "Step3", "replyTextFunc": "summary"}
+//	    ]
+//	  }]
+//	}
+func LoadWorkflows(r io.Reader, registry *FuncRegistry) ([]*TBotWorkflow, error) {
+	if registry == nil {
+		registry = NewFuncRegistry()
+	}
+
+	doc := workflowsDocument{}
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed decoding workflows: %w", err)
+	}
+
+	wfs := make([]*TBotWorkflow, 0, len(doc.Workflows))
+	for _, def := range doc.Workflows {
+		wf, err := def.build(registry)
+		if err != nil {
+			return nil, fmt.Errorf("workflow %s: %w", def.Name, err)
+		}
+		if err := wf.Validate(); err != nil {
+			return nil, err
+		}
+		wfs = append(wfs, wf)
+	}
+	return wfs, nil
+}
+
+func (def *workflowDefinition) build(registry *FuncRegistry) (*TBotWorkflow, error) {
+	if len(def.Steps) == 0 {
+		return nil, fmt.Errorf("no steps defined")
+	}
+
+	steps := make(map[string]*TBotWorkflowStep, len(def.Steps))
+	for i := range def.Steps {
+		step, err := def.Steps[i].build(registry)
+		if err != nil {
+			return nil, fmt.Errorf("step %s: %w", def.Steps[i].Name, err)
+		}
+		if _, found := steps[step.id()]; found {
+			return nil, fmt.Errorf("duplicate step ID %q", step.id())
+		}
+		steps[step.id()] = step
+	}
+
+	lookup := func(from string, id string) (*TBotWorkflowStep, error) {
+		step, found := steps[id]
+		if !found {
+			return nil, fmt.Errorf("step %s: unknown step %q", from, id)
+		}
+		return step, nil
+	}
+	for _, stepDef := range def.Steps {
+		step := steps[stepID(stepDef)]
+		if stepDef.Next != "" {
+			next, err := lookup(stepDef.Name, stepDef.Next)
+			if err != nil {
+				return nil, err
+			}
+			step.Next = next
+		}
+		for cond, id := range stepDef.ConditionalNext {
+			next, err := lookup(stepDef.Name, id)
+			if err != nil {
+				return nil, err
+			}
+			step.ConditionalNext[cond] = next
+		}
+	}
+
+	rootID := def.Root
+	if rootID == "" {
+		rootID = stepID(def.Steps[0])
+	}
+	root, found := steps[rootID]
+	if !found {
+		return nil, fmt.Errorf("unknown root step %q", rootID)
+	}
+
+	wf := NewWorkflow(def.Name, def.Command, root)
+	wf.CancelButtonConfig = def.CancelButton.cancelButtonConfig()
+	wf.BackButtonConfig = def.BackButton.backButtonConfig()
+	wf.ExpiredReplyText = def.ExpiredReplyText
+	wf.ChatScoped = def.ChatScoped
+	timeout, err := parseTimeout(def.IdleTimeout)
+	if err != nil {
+		return nil, err
+	}
+	wf.IdleTimeout = timeout
+
+	return &wf, nil
+}
+
+func (def *stepDefinition) build(registry *FuncRegistry) (*TBotWorkflowStep, error) {
+	var kb *tgbotapi.ReplyKeyboardMarkup
+	if len(def.Keyboard) > 0 {
+		rows := make([][]tgbotapi.KeyboardButton, 0, len(def.Keyboard))
+		for _, row := range def.Keyboard {
+			buttons := make([]tgbotapi.KeyboardButton, 0, len(row))
+			for _, text := range row {
+				buttons = append(buttons, tgbotapi.NewKeyboardButton(text))
+			}
+			rows = append(rows, buttons)
+		}
+		replyKB := tgbotapi.NewReplyKeyboard(rows...)
+		replyKB.Selective = true
+		kb = &replyKB
+	}
+
+	step := NewWorkflowStep(def.Name, def.Key, def.ReplyText, kb)
+	step.ID = def.ID
+	step.EditCallbackMessage = def.EditCallbackMessage
+	step.CancelButtonConfig = def.CancelButton.cancelButtonConfig()
+	step.BackButtonConfig = def.BackButton.backButtonConfig()
+	step.WrongInputKindReplyText = def.WrongInputReplyText
+	step.ValueType = def.ValueType
+	step.TimeLayout = def.TimeLayout
+	step.EnumValues = def.EnumValues
+	step.InvalidValueReplyText = def.InvalidValueReply
+	step.SubWorkflow = def.SubWorkflow
+	step.Repeat = def.Repeat.repeatConfig()
+	step.Summary = def.Summary.summaryConfig()
+
+	switch def.Input {
+	case "", InputText, InputPhoto, InputDocument, InputLocation, InputContact, InputVoice, InputVideo:
+		step.InputKind = def.Input
+	default:
+		return nil, fmt.Errorf("unknown input %q", def.Input)
+	}
+
+	if len(def.InlineKeyboard) > 0 {
+		rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(def.InlineKeyboard))
+		for _, row := range def.InlineKeyboard {
+			buttons := make([]tgbotapi.InlineKeyboardButton, 0, len(row))
+			for _, button := range row {
+				buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(button.Text, button.Data))
+			}
+			rows = append(rows, buttons)
+		}
+		inlineKB := tgbotapi.NewInlineKeyboardMarkup(rows...)
+		step.InlineKB = &inlineKB
+	}
+
+	if def.Validator != "" {
+		fn, found := registry.validator(def.Validator)
+		if !found {
+			return nil, fmt.Errorf("unknown validator %q", def.Validator)
+		}
+		step.ValidateInputFunc = fn
+	}
+	if def.Condition != "" {
+		fn, found := registry.condition(def.Condition)
+		if !found {
+			return nil, fmt.Errorf("unknown condition %q", def.Condition)
+		}
+		step.ConditionFunc = fn
+	}
+	if def.ReplyTextFunc != "" {
+		fn, found := registry.replyText(def.ReplyTextFunc)
+		if !found {
+			return nil, fmt.Errorf("unknown replyTextFunc %q", def.ReplyTextFunc)
+		}
+		step.ReplyTextFunc = fn
+	}
+
+	timeout, err := parseTimeout(def.IdleTimeout)
+	if err != nil {
+		return nil, err
+	}
+	step.IdleTimeout = timeout
+
+	return &step, nil
+}
+
+func stepID(def stepDefinition) string {
+	if def.ID != "" {
+		return def.ID
+	}
+	return def.Name
+}
+
+func (def *buttonDefinition) cancelButtonConfig() *CancelButtonConfig {
+	if def == nil {
+		return nil
+	}
+	return NewCancelButtonConfig(def.Text, def.Reply)
+}
+
+func (def *buttonDefinition) backButtonConfig() *BackButtonConfig {
+	if def == nil {
+		return nil
+	}
+	return NewBackButtonConfig(def.Text)
+}
+
+func (def *repeatDefinition) repeatConfig() *RepeatConfig {
+	if def == nil {
+		return nil
+	}
+	return NewRepeatConfig(def.DoneButton, def.Min, def.Max)
+}
+
+func (def *summaryDefinition) summaryConfig() *SummaryConfig {
+	if def == nil {
+		return nil
+	}
+	return NewSummaryConfig(def.ConfirmButton, def.EditButton)
+}
+
+func parseTimeout(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid idleTimeout %q: %w", s, err)
+	}
+	return timeout, nil
+}
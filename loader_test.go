@@ -0,0 +1,151 @@
+package tbotworkflow
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const condWorkflowJSON = `{
+  "workflows": [{
+    "name": "ConditionalWF",
+    "command": "cmd2",
+    "cancelButton": {"text": "RESET", "reply": "Clearing input. Please start again"},
+    "idleTimeout": "15m",
+    "chatScoped": true,
+    "steps": [
+      {"name": "Step1", "key": "K1", "replyText": "Please select an option",
+       "keyboard": [["Step1Option1", "Step1Option2"], ["Step1Option3", "Step1Option4"], ["RESET"]],
+       "next": "CondStep2"},
+      {"name": "CondStep2", "key": "CondK2", "replyText": "Please select a condition",
+       "keyboard": [["Step2Condition1", "Step2Condition2"], ["RESET"]],
+       "condition": "step2", "conditionalNext": {"C1": "C1Step3", "C2": "C2Step3"}},
+      {"name": "C1Step3", "key": "C1K3", "replyText": "Please select an option",
+       "keyboard": [["C1Step3Option1"], ["RESET"]], "next": "Step4"},
+      {"name": "C2Step3", "key": "C2K3", "replyText": "Please select an option",
+       "keyboard": [["C2Step3Option1"], ["RESET"]], "next": "Step4"},
+      {"name": "Step4", "replyTextFunc": "summary"}
+    ]
+  }]
+}`
+
+func TestLoadWorkflows(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+
+	registry := NewFuncRegistry()
+	registry.RegisterCondition("step2", func(msg *tgbotapi.Message) string {
+		return "C" + strings.TrimPrefix(msg.Text, "Step2Condition")
+	})
+	registry.RegisterReplyText("summary", func(ui *UserInputs) string {
+		return "Selected " + ui.Data["K1"]
+	})
+
+	wfs, err := LoadWorkflows(strings.NewReader(condWorkflowJSON), registry)
+	if err != nil {
+		t.Fatalf("Failed loading workflows. Error: %v", err)
+	}
+	if len(wfs) != 1 {
+		t.Fatalf("Expected 1 workflow but got %d", len(wfs))
+	}
+	if wfs[0].Command != "CMD2" || wfs[0].IdleTimeout != 15*time.Minute || !wfs[0].ChatScoped {
+		t.Errorf("Expected Command: CMD2, IdleTimeout: 15m, ChatScoped: true but got Command: %s, IdleTimeout: %v, ChatScoped: %v",
+			wfs[0].Command, wfs[0].IdleTimeout, wfs[0].ChatScoped)
+	}
+
+	wfc := NewWorkflowController("WFC")
+	if err := wfc.AddWorkflow(wfs[0]); err != nil {
+		t.Fatalf("Failed adding workflow. Error: %v", err)
+	}
+
+	var userInput *UserInputs
+	var done bool
+	for _, bi := range getCondBotInteractions() {
+		userInput, done = wfc.Execute(&bi.botMsg, mockSendFunc)
+		if done != bi.wfDone {
+			t.Errorf("Workflow done: %v at step %d but expected %v", done, bi.stepNo, bi.wfDone)
+		}
+	}
+	if userInput == nil || userInput.Data["C1K3"] != "C1Step3Option1" {
+		t.Errorf("Expected C1K3: C1Step3Option1 but got %v", userInput)
+	}
+	if sentMsgs[len(sentMsgs)-1].Text != "Selected Step1Option2" {
+		t.Errorf("Expected reply from summary ReplyTextFunc but got \"%s\"", sentMsgs[len(sentMsgs)-1].Text)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestLoadWorkflowsStubFuncs(t *testing.T) {
+	doc := `{"workflows": [{"name": "WF", "command": "cmd", "steps": [
+		{"name": "Step1", "key": "Plan", "validator": "plan", "condition": "plan", "conditionalNext": {"pro": "Step2"}},
+		{"name": "Step2", "replyTextFunc": "summary"}]}]}`
+	registry := NewStubFuncRegistry()
+	registry.RegisterReplyText("summary", func(ui *UserInputs) string { return "Summary" })
+	wfs, err := LoadWorkflows(strings.NewReader(doc), registry)
+	if err != nil {
+		t.Fatalf("Expected the unknown functions replaced by stubs but got %v", err)
+	}
+	step1 := wfs[0].RootStep
+	msg := mockBotMessage(1, "pro")
+	if _, ok := step1.ValidateInputFunc(&msg, nil); !ok || step1.ConditionFunc(&msg) != "" {
+		t.Error("Expected the stub validator to accept inputs and the stub condition to return an empty string")
+	}
+	if text := step1.ConditionalNext["pro"].ReplyTextFunc(&UserInputs{}); text != "Summary" {
+		t.Errorf("Expected the registered function to be used but got %s", text)
+	}
+}
+
+func TestLoadWorkflowsErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		doc           string
+		expectedError string
+	}{
+		{
+			name:          "UnknownField",
+			doc:           `{"workflows": [{"name": "WF", "comand": "cmd"}]}`,
+			expectedError: "unknown field",
+		},
+		{
+			name:          "NoSteps",
+			doc:           `{"workflows": [{"name": "WF", "command": "cmd"}]}`,
+			expectedError: "no steps defined",
+		},
+		{
+			name:          "UnknownNext",
+			doc:           `{"workflows": [{"name": "WF", "command": "cmd", "steps": [{"name": "Step1", "next": "Step2"}]}]}`,
+			expectedError: "step Step1: unknown step \"Step2\"",
+		},
+		{
+			name:          "UnknownValidator",
+			doc:           `{"workflows": [{"name": "WF", "command": "cmd", "steps": [{"name": "Step1", "validator": "email"}]}]}`,
+			expectedError: "unknown validator \"email\"",
+		},
+		{
+			name:          "UnknownInput",
+			doc:           `{"workflows": [{"name": "WF", "command": "cmd", "steps": [{"name": "Step1", "input": "sticker"}]}]}`,
+			expectedError: "unknown input \"sticker\"",
+		},
+		{
+			name:          "InvalidTimeout",
+			doc:           `{"workflows": [{"name": "WF", "command": "cmd", "idleTimeout": "soon", "steps": [{"name": "Step1"}]}]}`,
+			expectedError: "invalid idleTimeout \"soon\"",
+		},
+		{
+			name: "InvalidWorkflow",
+			doc: `{"workflows": [{"name": "WF", "command": "cmd", "steps": [
+				{"name": "Step1", "next": "Step2"}, {"name": "Step2", "next": "Step1"}]}]}`,
+			expectedError: "cycle without exit",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadWorkflows(strings.NewReader(tc.doc), nil)
+			if err == nil || !strings.Contains(err.Error(), tc.expectedError) {
+				t.Errorf("Expected error containing \"%s\" but got %v", tc.expectedError, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package tbotworkflow
+
+import "sync"
+
+// chatLocks serializes the processing of the messages of each chat.
+// Locking by chat covers both the sessions of the users in the chat and the session scoped to the chat.
+type chatLocks struct {
+	m     sync.Mutex
+	locks map[int64]*chatLock
+}
+
+type chatLock struct {
+	sync.Mutex
+	// Number of goroutines holding or waiting for the lock.
+	refs int
+}
+
+// lock blocks until the lock for the chat is acquired and returns the function releasing it.
+// Locks are dropped once released by all the goroutines, so idle chats do not hold memory.
+func (c *chatLocks) lock(chatID int64) func() {
+	c.m.Lock()
+	if c.locks == nil {
+		c.locks = make(map[int64]*chatLock)
+	}
+	l, found := c.locks[chatID]
+	if !found {
+		l = &chatLock{}
+		c.locks[chatID] = l
+	}
+	l.refs++
+	c.m.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		c.m.Lock()
+		defer c.m.Unlock()
+		if l.refs--; l.refs == 0 {
+			delete(c.locks, chatID)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package tbotworkflow
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// syncSendFunc is a send function safe for concurrent use.
+func syncSendFunc() func(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	var m sync.Mutex
+	return func(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+		m.Lock()
+		defer m.Unlock()
+		return tgbotapi.Message{}, nil
+	}
+}
+
+// slowSessionStore widens the window between loading and saving a session.
+type slowSessionStore struct {
+	SessionStore
+}
+
+func (s slowSessionStore) Get(key SessionKey) (*Session, bool, error) {
+	session, found, err := s.SessionStore.Get(key)
+	time.Sleep(time.Millisecond)
+	return session, found, err
+}
+
+func TestConcurrentExecute(t *testing.T) {
+	wfc := NewWorkflowController("WFC")
+	seqWF := newSeqWorkflow("CMD1")
+	mustAddWorkflow(t, wfc, &seqWF)
+	send := syncSendFunc()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 50)
+	for chatID := int64(1); chatID <= 50; chatID++ {
+		wg.Add(1)
+		go func(chatID int64) {
+			defer wg.Done()
+			msgs := []tgbotapi.Message{
+				mockBotCommand(chatID, "/CMD1"),
+				mockBotMessage(chatID, "Step1Option2"),
+				mockBotMessage(chatID, "Step2Option4"),
+			}
+			var userInput *UserInputs
+			for i := range msgs {
+				userInput, _ = wfc.Execute(&msgs[i], send)
+			}
+			if userInput == nil || userInput.ChatID != chatID || userInput.Data["K2"] != "Step2Option4" {
+				errs <- fmt.Errorf("chat %d: expected completed workflow but got %v", chatID, userInput)
+			}
+		}(chatID)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestConcurrentExecuteSameUser(t *testing.T) {
+	wfc := NewWorkflowController("WFC")
+	wfc.SetSessionStore(slowSessionStore{NewMemorySessionStore()})
+	seqWF := newSeqWorkflow("CMD1")
+	mustAddWorkflow(t, wfc, &seqWF)
+	send := syncSendFunc()
+
+	msg := mockBotCommand(1, "/CMD1")
+	wfc.Execute(&msg, send)
+
+	// The same answer sent many times at once must move the workflow only once.
+	count := func(text string) map[Result]int {
+		var wg sync.WaitGroup
+		var m sync.Mutex
+		results := make(map[Result]int)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				msg := mockBotMessage(1, text)
+				_, result, _ := wfc.ExecuteE(&msg, send)
+				m.Lock()
+				results[result]++
+				m.Unlock()
+			}()
+		}
+		wg.Wait()
+		return results
+	}
+
+	results := count("Step1Option1")
+	if results[ResultInProgress] != 1 || results[ResultValidationFailed] != 19 {
+		t.Errorf("Expected Step1 answered once and 19 invalid inputs but got %v", results)
+	}
+	results = count("Step2Option1")
+	if results[ResultCompleted] != 1 || results[ResultNotFound] != 19 {
+		t.Errorf("Expected workflow completed once but got %v", results)
+	}
+}
+
+func TestConcurrentExpire(t *testing.T) {
+	wfc := NewWorkflowController("WFC")
+	seqWF := newSeqWorkflow("CMD1")
+	seqWF.IdleTimeout = time.Millisecond
+	mustAddWorkflow(t, wfc, &seqWF)
+	send := syncSendFunc()
+
+	stop := wfc.StartJanitor(time.Millisecond, send)
+	var wg sync.WaitGroup
+	for chatID := int64(1); chatID <= 10; chatID++ {
+		wg.Add(1)
+		go func(chatID int64) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				msg := mockBotCommand(chatID, "/CMD1")
+				wfc.Execute(&msg, send)
+				msg = mockBotMessage(chatID, "Step1Option1")
+				wfc.Execute(&msg, send)
+			}
+		}(chatID)
+	}
+	wg.Wait()
+	stop()
+}
+
+func TestChatLocks(t *testing.T) {
+	var locks chatLocks
+	unlock := locks.lock(1)
+	acquired := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer locks.lock(1)()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("Expected the lock of the chat to be held")
+	case <-time.After(10 * time.Millisecond):
+	}
+	locks.lock(2)()
+	unlock()
+	<-acquired
+	<-done
+
+	locks.m.Lock()
+	defer locks.m.Unlock()
+	if len(locks.locks) != 0 {
+		t.Errorf("Expected released locks to be dropped but got %d", len(locks.locks))
+	}
+}
@@ -0,0 +1,249 @@
+package tbotworkflow
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Names of the metrics recorded by the controller.
+const (
+	// Counter of the workflows started by the users.
+	MetricStarts string = "tbotworkflow_starts_total"
+	// Counter of the workflows completed by the users.
+	MetricCompletions string = "tbotworkflow_completions_total"
+	// Counter of the workflows cancelled with the cancel button, by step.
+	MetricCancellations string = "tbotworkflow_cancellations_total"
+	// Counter of the user inputs rejected by the validation, by step.
+	MetricValidationFailures string = "tbotworkflow_validation_failures_total"
+	// Counter of the workflows ended because the next step could not be determined, by step.
+	MetricBroken string = "tbotworkflow_broken_total"
+	// Counter of the messages for which there is no workflow.
+	MetricNotFound string = "tbotworkflow_not_found_total"
+	// Histogram of the seconds the users spend at a step before leaving it.
+	MetricStepDwell string = "tbotworkflow_step_dwell_seconds"
+)
+
+var metricHelp = map[string]string{
+	MetricStarts:             "Workflows started by the users.",
+	MetricCompletions:        "Workflows completed by the users.",
+	MetricCancellations:      "Workflows cancelled with the cancel button.",
+	MetricValidationFailures: "User inputs rejected by the validation.",
+	MetricBroken:             "Workflows ended because the next step could not be determined.",
+	MetricNotFound:           "Messages for which there is no workflow.",
+	MetricStepDwell:          "Seconds the users spend at a step before leaving it.",
+}
+
+// MetricLabels are the labels of a metric. Labels not relevant to the metric are left empty.
+type MetricLabels struct {
+	// Name of the workflow.
+	Workflow string
+	// ID of the step.
+	Step string
+}
+
+// Metrics records the counters and histograms of the workflows run by the controller,
+// e.g. to find out at which steps the users abandon a workflow.
+// The methods are called while the messages are processed and must be safe for concurrent use.
+type Metrics interface {
+	// IncCounter adds one to the counter with the name and labels.
+	IncCounter(name string, labels MetricLabels)
+	// ObserveHistogram adds the value to the histogram with the name and labels.
+	ObserveHistogram(name string, value float64, labels MetricLabels)
+}
+
+// DefaultDwellBuckets are the upper bounds in seconds of the buckets of the histograms of PrometheusMetrics.
+var DefaultDwellBuckets = []float64{1, 5, 15, 30, 60, 300, 900, 3600}
+
+// PrometheusMetrics is a Metrics keeping the metrics in memory and serving them
+// in the Prometheus text exposition format, e.g. with http.Handle("/metrics", metrics).
+type PrometheusMetrics struct {
+	m          sync.Mutex
+	counters   map[string]map[MetricLabels]float64
+	histograms map[string]map[MetricLabels]*histogram
+	// Upper bounds of the buckets of the histograms, in increasing order.
+	// Defaults to DefaultDwellBuckets. Must not be changed once values are observed.
+	Buckets []float64
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewPrometheusMetrics returns a pointer to an empty PrometheusMetrics.
+func NewPrometheusMetrics() *PrometheusMetrics {
+	return &PrometheusMetrics{
+		counters:   make(map[string]map[MetricLabels]float64),
+		histograms: make(map[string]map[MetricLabels]*histogram),
+		Buckets:    DefaultDwellBuckets,
+	}
+}
+
+// IncCounter adds one to the counter with the name and labels.
+func (p *PrometheusMetrics) IncCounter(name string, labels MetricLabels) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if p.counters[name] == nil {
+		p.counters[name] = make(map[MetricLabels]float64)
+	}
+	p.counters[name][labels]++
+}
+
+// ObserveHistogram adds the value to the histogram with the name and labels.
+func (p *PrometheusMetrics) ObserveHistogram(name string, value float64, labels MetricLabels) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	if p.histograms[name] == nil {
+		p.histograms[name] = make(map[MetricLabels]*histogram)
+	}
+	h := p.histograms[name][labels]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(p.Buckets))}
+		p.histograms[name][labels] = h
+	}
+	for i, bound := range p.Buckets {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (p *PrometheusMetrics) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	fmt.Fprint(rw, p.String())
+}
+
+// String returns the metrics in the Prometheus text exposition format, sorted by name and labels.
+func (p *PrometheusMetrics) String() string {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	counterNames := make([]string, 0, len(p.counters))
+	for name := range p.counters {
+		counterNames = append(counterNames, name)
+	}
+	sort.Strings(counterNames)
+	histogramNames := make([]string, 0, len(p.histograms))
+	for name := range p.histograms {
+		histogramNames = append(histogramNames, name)
+	}
+	sort.Strings(histogramNames)
+
+	var b strings.Builder
+	for _, name := range counterNames {
+		writeMetricHeader(&b, name, "counter")
+		keys := make([]MetricLabels, 0, len(p.counters[name]))
+		for labels := range p.counters[name] {
+			keys = append(keys, labels)
+		}
+		for _, labels := range sortedLabels(keys) {
+			fmt.Fprintf(&b, "%s%s %s\n", name, formatLabels(labels, ""), formatValue(p.counters[name][labels]))
+		}
+	}
+	for _, name := range histogramNames {
+		writeMetricHeader(&b, name, "histogram")
+		keys := make([]MetricLabels, 0, len(p.histograms[name]))
+		for labels := range p.histograms[name] {
+			keys = append(keys, labels)
+		}
+		for _, labels := range sortedLabels(keys) {
+			h := p.histograms[name][labels]
+			for i, bound := range p.Buckets {
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", name, formatLabels(labels, formatValue(bound)), h.counts[i])
+			}
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", name, formatLabels(labels, "+Inf"), h.count)
+			fmt.Fprintf(&b, "%s_sum%s %s\n", name, formatLabels(labels, ""), formatValue(h.sum))
+			fmt.Fprintf(&b, "%s_count%s %d\n", name, formatLabels(labels, ""), h.count)
+		}
+	}
+	return b.String()
+}
+
+func writeMetricHeader(b *strings.Builder, name string, metricType string) {
+	if help, found := metricHelp[name]; found {
+		fmt.Fprintf(b, "# HELP %s %s\n", name, help)
+	}
+	fmt.Fprintf(b, "# TYPE %s %s\n", name, metricType)
+}
+
+// formatLabels returns the non empty labels, followed by the le label of histogram buckets if set.
+func formatLabels(labels MetricLabels, le string) string {
+	pairs := []string{}
+	if labels.Workflow != "" {
+		pairs = append(pairs, fmt.Sprintf("workflow=\"%s\"", escapeLabel(labels.Workflow)))
+	}
+	if labels.Step != "" {
+		pairs = append(pairs, fmt.Sprintf("step=\"%s\"", escapeLabel(labels.Step)))
+	}
+	if le != "" {
+		pairs = append(pairs, fmt.Sprintf("le=\"%s\"", le))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+func sortedLabels(labels []MetricLabels) []MetricLabels {
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].Workflow != labels[j].Workflow {
+			return labels[i].Workflow < labels[j].Workflow
+		}
+		return labels[i].Step < labels[j].Step
+	})
+	return labels
+}
+
+// incCounter adds one to the counter of the Metrics of the controller, if any.
+func (w *TBotWorkflowController) incCounter(name string, labels MetricLabels) {
+	if w.Metrics != nil {
+		w.Metrics.IncCounter(name, labels)
+	}
+}
+
+// stepLabels returns the labels of the CurrentStep.
+func (t *workflowTracker) stepLabels() MetricLabels {
+	return MetricLabels{Workflow: t.current().Name, Step: t.CurrentStep.id()}
+}
+
+// observeDwell records the time the user spent at the CurrentStep.
+// Steps running a SubWorkflow are left out since the user spends the time at the steps of the sub-workflow.
+func (w *TBotWorkflowController) observeDwell(t *workflowTracker) {
+	if w.Metrics == nil || t.enteredAt.IsZero() || t.CurrentStep.SubWorkflow != "" {
+		return
+	}
+	dwell := w.now().Sub(t.enteredAt)
+	if dwell < 0 {
+		dwell = 0
+	}
+	w.Metrics.ObserveHistogram(MetricStepDwell, dwell.Seconds(), t.stepLabels())
+}
+
+// stepEntered records the time the user entered the CurrentStep.
+func (w *TBotWorkflowController) stepEntered(t *workflowTracker) {
+	t.enteredAt = time.Time{}
+	if w.now != nil {
+		t.enteredAt = w.now()
+	}
+}
@@ -0,0 +1,75 @@
+package tbotworkflow
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestMetrics(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	metrics := NewPrometheusMetrics()
+	wfc := NewWorkflowController("WFC")
+	wfc.Metrics = metrics
+	clock := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	wfc.now = func() time.Time { return clock }
+	signupWF := newSignupWorkflow("SIGNUP")
+	mustAddWorkflow(t, wfc, &signupWF)
+
+	step1 := NewWorkflowStep("Step1", "K1", "Text", nil)
+	step2 := NewWorkflowStep("Step2", "", "Text", nil)
+	step1.ConditionFunc = func(msg *tgbotapi.Message) string { return msg.Text }
+	step1.ConditionalNext["A"] = &step2
+	brokenWF := NewWorkflow("BrokenWF", "BROKEN", &step1)
+	mustAddWorkflow(t, wfc, &brokenWF)
+
+	runMessages(wfc, 1, "/SIGNUP")
+	clock = clock.Add(10 * time.Second)
+	runMessages(wfc, 1, "Alice")
+	clock = clock.Add(2 * time.Second)
+	runMessages(wfc, 1, "a@example.com", "Gold", "Pro", "5", "Confirm")
+	runMessages(wfc, 2, "/SIGNUP", "RESET")
+	runMessages(wfc, 3, "/UNKNOWN")
+	runMessages(wfc, 4, "/BROKEN", "B")
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Expected Prometheus text format but got %s", contentType)
+	}
+	body := rec.Body.String()
+	expected := []string{
+		"# TYPE tbotworkflow_starts_total counter",
+		`tbotworkflow_starts_total{workflow="SignupWF"} 2`,
+		`tbotworkflow_completions_total{workflow="SignupWF"} 1`,
+		`tbotworkflow_cancellations_total{workflow="SignupWF",step="Name"} 1`,
+		`tbotworkflow_validation_failures_total{workflow="SignupWF",step="Plan"} 1`,
+		`tbotworkflow_broken_total{workflow="BrokenWF",step="Step1"} 1`,
+		"tbotworkflow_not_found_total 1",
+		"# TYPE tbotworkflow_step_dwell_seconds histogram",
+		`tbotworkflow_step_dwell_seconds_bucket{workflow="SignupWF",step="Name",le="5"} 0`,
+		`tbotworkflow_step_dwell_seconds_bucket{workflow="SignupWF",step="Name",le="15"} 1`,
+		`tbotworkflow_step_dwell_seconds_bucket{workflow="SignupWF",step="Name",le="+Inf"} 1`,
+		`tbotworkflow_step_dwell_seconds_sum{workflow="SignupWF",step="Name"} 10`,
+		`tbotworkflow_step_dwell_seconds_sum{workflow="SignupWF",step="Email"} 2`,
+		`tbotworkflow_step_dwell_seconds_count{workflow="SignupWF",step="Plan"} 1`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected metrics to contain %s but got\n%s", line, body)
+		}
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestPrometheusMetricsLabels(t *testing.T) {
+	metrics := NewPrometheusMetrics()
+	metrics.IncCounter("custom_total", MetricLabels{Workflow: "WF \"1\"", Step: "a\\b"})
+	expected := "# TYPE custom_total counter\ncustom_total{workflow=\"WF \\\"1\\\"\",step=\"a\\\\b\"} 1\n"
+	if got := metrics.String(); got != expected {
+		t.Errorf("Expected escaped labels %q but got %q", expected, got)
+	}
+}
@@ -0,0 +1,103 @@
+package tbotworkflow
+
+import (
+	"context"
+	"fmt"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// SendFunc sends a message to the user, e.g. the Send function of the Telegram Bot API.
+type SendFunc func(c tgbotapi.Chattable) (tgbotapi.Message, error)
+
+// Handler processes a Message from the user.
+// session is the workflow progress of the user before the message is processed, or nil if the user is not in a workflow.
+// Messages are sent to the user with send.
+type Handler func(ctx context.Context, msg *tgbotapi.Message, session *Session, send SendFunc) (*UserInputs, Result, error)
+
+// Middleware wraps the Handler which runs the workflows.
+// A Middleware can short-circuit by replying to the user with send and returning without calling next,
+// or decorate send before passing it to next.
+type Middleware func(next Handler) Handler
+
+// Use adds middleware around the execution of the workflows.
+// Middleware runs in the order it is added, i.e. the first Middleware is the outermost.
+// Middleware applies to Execute, ExecuteE, ExecuteUpdate and ExecuteUpdateE, including the CallbackQuery of inline keyboards.
+func (w *TBotWorkflowController) Use(middleware ...Middleware) {
+	w.middleware = append(w.middleware, middleware...)
+}
+
+// Recover returns a Middleware which recovers from panics in the workflows and the Middleware it wraps.
+// The panic is logged to the Logger of the controller and returned as an error with ResultAborted.
+// The session of the user is not saved, so the user stays at the step they were at before the message.
+func (w *TBotWorkflowController) Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg *tgbotapi.Message, session *Session, send SendFunc) (ui *UserInputs, result Result, err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					w.Logger.Printf("Recovered from panic processing message: %s. Panic: %v", msg.Text, r)
+					ui, result, err = nil, ResultAborted, fmt.Errorf("recovered from panic: %v", r)
+				}
+			}()
+			return next(ctx, msg, session, send)
+		}
+	}
+}
+
+// handle runs the message through the middleware and the workflows.
+func (w *TBotWorkflowController) handle(ctx context.Context, msg *tgbotapi.Message, callback *tgbotapi.CallbackQuery,
+	sendFunc func(c tgbotapi.Chattable) (tgbotapi.Message, error)) (ui *UserInputs, result Result, err error) {
+	// Messages without a sender, e.g. channel posts, cannot be tied to a session.
+	if msg == nil || msg.Chat == nil || msg.From == nil {
+		w.Logger.Printf("Ignoring message without chat or sender")
+		return nil, ResultNotFound, nil
+	}
+	unlock := w.chatLocks.lock(msg.Chat.ID)
+	defer unlock()
+
+	if w.Recorder != nil {
+		event := TranscriptEvent{Type: TranscriptMessage, Message: msg}
+		if callback != nil {
+			event.Message, event.Callback = nil, callback
+		}
+		w.record(msg, event)
+		sendFunc = w.recordingSend(msg, sendFunc)
+		defer func() {
+			event := TranscriptEvent{Type: TranscriptResult, Result: result.String()}
+			if err != nil {
+				event.Error = err.Error()
+			}
+			w.record(msg, event)
+		}()
+	}
+
+	if len(w.middleware) == 0 {
+		return w.execute(ctx, msg, callback, sendFunc)
+	}
+
+	var h Handler = func(ctx context.Context, msg *tgbotapi.Message, session *Session, send SendFunc) (*UserInputs, Result, error) {
+		return w.execute(ctx, msg, callback, send)
+	}
+	for i := len(w.middleware) - 1; i >= 0; i-- {
+		h = w.middleware[i](h)
+	}
+	return h(ctx, msg, w.currentSession(msg), sendFunc)
+}
+
+// currentSession returns a copy of the session the message would be processed in, or nil if there is none.
+func (w *TBotWorkflowController) currentSession(msg *tgbotapi.Message) *Session {
+	if w.sessionStore == nil {
+		return nil
+	}
+	for _, key := range []SessionKey{{ChatID: msg.Chat.ID, UID: msg.From.ID}, {ChatID: msg.Chat.ID}} {
+		session, found, err := w.sessionStore.Get(key)
+		if err != nil {
+			w.Logger.Printf("Failed loading session. Error: %v", err)
+			return nil
+		}
+		if found {
+			return session.clone()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package tbotworkflow
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestMiddleware(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	seqWF := newSeqWorkflow("CMD1")
+	mustAddWorkflow(t, wfc, &seqWF)
+
+	var calls []string
+	var sessions []*Session
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, msg *tgbotapi.Message, session *Session, send SendFunc) (*UserInputs, Result, error) {
+				calls = append(calls, name+">")
+				ui, result, err := next(ctx, msg, session, send)
+				calls = append(calls, "<"+name)
+				return ui, result, err
+			}
+		}
+	}
+	captureSession := func(next Handler) Handler {
+		return func(ctx context.Context, msg *tgbotapi.Message, session *Session, send SendFunc) (*UserInputs, Result, error) {
+			sessions = append(sessions, session)
+			return next(ctx, msg, session, send)
+		}
+	}
+	// Decorates the send function to sign all the messages.
+	signature := func(next Handler) Handler {
+		return func(ctx context.Context, msg *tgbotapi.Message, session *Session, send SendFunc) (*UserInputs, Result, error) {
+			return next(ctx, msg, session, func(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+				if reply, ok := c.(tgbotapi.MessageConfig); ok {
+					reply.Text += " - Bot"
+					c = reply
+				}
+				return send(c)
+			})
+		}
+	}
+	wfc.Use(trace("outer"), trace("inner"))
+	wfc.Use(captureSession, signature)
+
+	var userInput *UserInputs
+	for _, text := range []string{"/CMD1", "Step1Option1", "Step2Option3"} {
+		calls = nil
+		msg := mockBotMessage(1, text)
+		if text[0] == '/' {
+			msg = mockBotCommand(1, text)
+		}
+		userInput, _ = wfc.Execute(&msg, mockSendFunc)
+
+		expectedCalls := []string{"outer>", "inner>", "<inner", "<outer"}
+		if !reflect.DeepEqual(calls, expectedCalls) {
+			t.Errorf("Input:%s Expected middleware calls %v but got %v", text, expectedCalls, calls)
+		}
+	}
+
+	if userInput == nil || userInput.Data["K2"] != "Step2Option3" {
+		t.Errorf("Expected workflow to complete through the middleware but got %v", userInput)
+	}
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Please verify the selected Options - Bot" {
+		t.Errorf("Expected the send function decorated by the middleware but got %s", reply)
+	}
+	if sessions[0] != nil {
+		t.Errorf("Expected no session before the workflow is started but got %v", sessions[0])
+	}
+	if sessions[1] == nil || sessions[1].StepID != "Step1" {
+		t.Errorf("Expected session at Step1 but got %v", sessions[1])
+	}
+	if sessions[2] == nil || sessions[2].StepID != "Step2" || sessions[2].UserInputs.Data["K1"] != "Step1Option1" {
+		t.Errorf("Expected session at Step2 but got %v", sessions[2])
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	seqWF := newSeqWorkflow("CMD1")
+	mustAddWorkflow(t, wfc, &seqWF)
+
+	allowed := map[int64]bool{1: true}
+	wfc.Use(func(next Handler) Handler {
+		return func(ctx context.Context, msg *tgbotapi.Message, session *Session, send SendFunc) (*UserInputs, Result, error) {
+			if !allowed[msg.Chat.ID] {
+				send(tgbotapi.NewMessage(msg.Chat.ID, "Not authorized"))
+				return nil, ResultNotFound, nil
+			}
+			return next(ctx, msg, session, send)
+		}
+	})
+
+	msg := mockBotCommand(2, "/CMD1")
+	if _, result, err := wfc.ExecuteE(&msg, mockSendFunc); result != ResultNotFound || err != nil {
+		t.Errorf("Expected short-circuit with ResultNotFound but got %v/%v", result, err)
+	}
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Not authorized" {
+		t.Errorf("Expected reply from the middleware but got %s", reply)
+	}
+
+	// Callbacks are answered within the Middleware.
+	sentChattables = []tgbotapi.Chattable{}
+	wfc.ExecuteUpdate(mockCallbackUpdate(2, "cb1", "Step1Option1"), mockSendFunc)
+	for _, c := range sentChattables {
+		if _, ok := c.(tgbotapi.CallbackConfig); ok {
+			t.Errorf("Expected the callback of an unauthorized chat not to be answered but got %v", c)
+		}
+	}
+
+	msg = mockBotCommand(1, "/CMD1")
+	if _, result, _ := wfc.ExecuteE(&msg, mockSendFunc); result != ResultInProgress {
+		t.Errorf("Expected authorized user to start the workflow but got %v", result)
+	}
+	sentMsgs = []tgbotapi.Message{}
+	sentChattables = []tgbotapi.Chattable{}
+}
+
+func TestRecover(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	seqWF := newSeqWorkflow("CMD1")
+	seqWF.RootStep.ValidateInputFunc = func(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool) {
+		panic("validator bug")
+	}
+	mustAddWorkflow(t, wfc, &seqWF)
+	wfc.Use(wfc.Recover())
+
+	msg := mockBotCommand(1, "/CMD1")
+	wfc.ExecuteE(&msg, mockSendFunc)
+	msg = mockBotMessage(1, "Step1Option1")
+	userInput, result, err := wfc.ExecuteE(&msg, mockSendFunc)
+	if userInput != nil || result != ResultAborted || err == nil {
+		t.Errorf("Expected recovered panic but got %v/%v/%v", userInput, result, err)
+	}
+	if session := wfc.currentSession(&msg); session == nil || session.StepID != seqWF.RootStep.Name {
+		t.Errorf("Expected the user to stay at the first step but got %+v", session)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
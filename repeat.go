@@ -0,0 +1,101 @@
+package tbotworkflow
+
+import (
+	"fmt"
+)
+
+const (
+	// Default Text sent to the user who presses the done button before giving the minimum number of inputs.
+	defaultRepeatMinReplyText string = "Please add at least %d before finishing"
+)
+
+// RepeatConfig will tell the workflow to repeat a step to collect a list of user inputs.
+type RepeatConfig struct {
+	doneButtonText string
+	min            int
+	max            int
+}
+
+// NewRepeatConfig returns a pointer to repeat config.
+// doneButtonText: Tells the workflow step what is the done buttons text. E.g. "Done", "Checkout" etc.
+// When the user presses the Done button, the workflow moves on to the next step.
+// Set to empty string for steps ended only by the maximum or the RepeatUntilFunc.
+// min: Number of inputs required before the Done button is accepted or the RepeatUntilFunc can end the step.
+// max: Number of inputs after which the workflow moves on without the Done button. Set to 0 for no limit.
+func NewRepeatConfig(doneButtonText string, min int, max int) *RepeatConfig {
+	return &RepeatConfig{
+		doneButtonText: doneButtonText,
+		min:            min,
+		max:            max,
+	}
+}
+
+// isDone tells if the message is the done button of a repeatable step.
+// Steps without a done button, e.g. ended by the maximum or RepeatUntilFunc, are never done by a message.
+func (s *TBotWorkflowStep) isDone(text string) bool {
+	return s.Repeat != nil && s.Repeat.doneButtonText != "" && text == s.Repeat.doneButtonText
+}
+
+// items returns the number of inputs given to the repeatable CurrentStep.
+func (t *workflowTracker) items() int {
+	return len(t.userInputs.Lists[t.dataKey(t.CurrentStep.Key)])
+}
+
+// addItem appends the input to the list of the repeatable CurrentStep.
+func (t *workflowTracker) addItem(input InputValue) {
+	key := t.dataKey(t.CurrentStep.Key)
+	t.userInputs.Lists[key] = append(t.userInputs.Lists[key], input.String())
+	t.userInputs.ListInputs[key] = append(t.userInputs.ListInputs[key], input)
+}
+
+// removeLastItem removes the last input given to the repeatable CurrentStep.
+// Returns false if the CurrentStep is not repeatable or has no inputs yet.
+func (t *workflowTracker) removeLastItem() bool {
+	if t.CurrentStep.Repeat == nil || t.items() == 0 {
+		return false
+	}
+	key := t.dataKey(t.CurrentStep.Key)
+	t.userInputs.Lists[key] = t.userInputs.Lists[key][:t.items()-1]
+	t.userInputs.ListInputs[key] = t.userInputs.ListInputs[key][:len(t.userInputs.ListInputs[key])-1]
+	return true
+}
+
+// deleteItems removes all the inputs given to the repeatable step.
+func (t *workflowTracker) deleteItems(key string) {
+	delete(t.userInputs.Lists, key)
+	delete(t.userInputs.ListInputs, key)
+}
+
+// validateDone checks that the repeatable CurrentStep has the minimum number of inputs.
+func (w *TBotWorkflowController) validateDone(t *workflowTracker) (string, bool) {
+	if t.items() < t.CurrentStep.Repeat.min {
+		return fmt.Sprintf(w.translate(t.userInputs.Language, defaultRepeatMinReplyText), t.CurrentStep.Repeat.min), false
+	}
+	return "", true
+}
+
+// repeatFull tells if the repeatable CurrentStep reached its maximum number of inputs.
+// The list is already full when the user comes back to the step, e.g. with the back button.
+func (t *workflowTracker) repeatFull() bool {
+	return t.CurrentStep.Repeat.max > 0 && t.items() >= t.CurrentStep.Repeat.max
+}
+
+// repeatEnded tells if the repeatable CurrentStep reached its maximum number of inputs,
+// or its minimum number of inputs and its RepeatUntilFunc.
+func (t *workflowTracker) repeatEnded() bool {
+	if t.repeatFull() {
+		return true
+	}
+	return t.items() >= t.CurrentStep.Repeat.min &&
+		t.CurrentStep.RepeatUntilFunc != nil && t.CurrentStep.RepeatUntilFunc(&t.userInputs)
+}
+
+// List returns the user inputs for the key of a repeatable step, in the order they were given.
+// Returns ErrInputNotFound if the user did not provide an input for the key.
+func (ui *UserInputs) List(key string) ([]string, error) {
+	list, found := ui.Lists[key]
+	if !found {
+		return nil, fmt.Errorf("%w for Key: %s", ErrInputNotFound, key)
+	}
+	return list, nil
+}
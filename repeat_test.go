@@ -0,0 +1,226 @@
+package tbotworkflow
+
+import (
+	"reflect"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// newCartWorkflow returns a workflow collecting between 1 and 3 items followed by an address.
+func newCartWorkflow(cmd string) TBotWorkflow {
+	itemsKB := tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("Apple"),
+			tgbotapi.NewKeyboardButton("Pear"),
+		),
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("Done"),
+			tgbotapi.NewKeyboardButton("BACK"),
+		),
+	)
+	items := NewWorkflowStep("Items", "Items", "Please add an item", &itemsKB)
+	items.Repeat = NewRepeatConfig("Done", 1, 3)
+	address := NewWorkflowStep("Address", "Address", "Please enter your address", nil)
+	confirm := NewWorkflowStep("Confirm", "", "Thanks for your order", nil)
+	items.Next = &address
+	address.Next = &confirm
+
+	wf := NewWorkflow("CartWF", cmd, &items)
+	wf.BackButtonConfig = NewBackButtonConfig("BACK")
+	return wf
+}
+
+func TestRepeatableStep(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	cartWF := newCartWorkflow("CART")
+	mustAddWorkflow(t, wfc, &cartWF)
+
+	_, result, _ := runMessages(wfc, 1, "/CART", "Done")
+	if result != ResultValidationFailed {
+		t.Errorf("Expected done without items to be rejected but got %v", result)
+	}
+	if reply := sentMsgs[len(sentMsgs)-2].Text; reply != "Please add at least 1 before finishing" {
+		t.Errorf("Expected minimum count reply but got %s", reply)
+	}
+
+	runMessages(wfc, 1, "Apple")
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Please add an item" {
+		t.Errorf("Expected the step to be repeated but got %s", reply)
+	}
+	runMessages(wfc, 1, "Pear", "Done")
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Please enter your address" {
+		t.Errorf("Expected to move on with the done button but got %s", reply)
+	}
+
+	userInput, result, _ := runMessages(wfc, 1, "Main St")
+	if result != ResultCompleted {
+		t.Fatalf("Expected completed workflow but got %v", result)
+	}
+	items, err := userInput.List("Items")
+	if err != nil || !reflect.DeepEqual(items, []string{"Apple", "Pear"}) {
+		t.Errorf("Expected the items in order but got %v/%v", items, err)
+	}
+	if len(userInput.ListInputs["Items"]) != 2 || userInput.ListInputs["Items"][1].Text != "Pear" {
+		t.Errorf("Expected the structured items but got %v", userInput.ListInputs["Items"])
+	}
+	if _, found := userInput.Data["Items"]; found {
+		t.Errorf("Expected the items not to be stored in Data but got %v", userInput.Data)
+	}
+	if _, err := userInput.List("Address"); err == nil {
+		t.Error("Expected ErrInputNotFound for a step that is not repeatable")
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestRepeatableStepMax(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	cartWF := newCartWorkflow("CART")
+	mustAddWorkflow(t, wfc, &cartWF)
+
+	runMessages(wfc, 1, "/CART", "Apple", "Apple", "Pear")
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Please enter your address" {
+		t.Errorf("Expected to move on once the maximum is reached but got %s", reply)
+	}
+
+	// Back to a full list does not add more items.
+	userInput, result, _ := runMessages(wfc, 1, "BACK", "Pear", "Main St")
+	if result != ResultCompleted {
+		t.Fatalf("Expected completed workflow but got %v", result)
+	}
+	if items := userInput.Lists["Items"]; !reflect.DeepEqual(items, []string{"Apple", "Apple", "Pear"}) {
+		t.Errorf("Expected at most 3 items but got %v", items)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestRepeatableStepBack(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	cartWF := newCartWorkflow("CART")
+	mustAddWorkflow(t, wfc, &cartWF)
+	msg := mockBotMessage(1, "")
+
+	// Back removes the last item.
+	runMessages(wfc, 1, "/CART", "Apple", "Pear", "BACK")
+	session := wfc.currentSession(&msg)
+	if session == nil || session.StepID != "Items" || !reflect.DeepEqual(session.UserInputs.Lists["Items"], []string{"Apple"}) {
+		t.Errorf("Expected the last item removed but got %+v", session)
+	}
+
+	// Back to the repeatable step keeps its items.
+	runMessages(wfc, 1, "Done", "BACK")
+	session = wfc.currentSession(&msg)
+	if session == nil || session.StepID != "Items" || len(session.UserInputs.Lists["Items"]) != 1 {
+		t.Errorf("Expected to be back at Items with the items kept but got %+v", session)
+	}
+
+	// Back without items goes back to the previous step, or repeats the first step.
+	runMessages(wfc, 1, "BACK", "BACK")
+	session = wfc.currentSession(&msg)
+	if session == nil || session.StepID != "Items" || len(session.UserInputs.Lists["Items"]) != 0 {
+		t.Errorf("Expected to stay at Items without items but got %+v", session)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestRepeatUntilFunc(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	cartWF := newCartWorkflow("CART")
+	cartWF.RootStep.Repeat = NewRepeatConfig("Done", 0, 0)
+	cartWF.RootStep.RepeatUntilFunc = func(ui *UserInputs) bool {
+		items := ui.Lists["Items"]
+		return items[len(items)-1] == "Pear"
+	}
+	mustAddWorkflow(t, wfc, &cartWF)
+
+	runMessages(wfc, 1, "/CART", "Apple", "Apple", "Apple", "Apple")
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Please add an item" {
+		t.Errorf("Expected the step to be repeated without maximum but got %s", reply)
+	}
+	userInput, result, _ := runMessages(wfc, 1, "Pear", "Main St")
+	if result != ResultCompleted || len(userInput.Lists["Items"]) != 5 {
+		t.Errorf("Expected RepeatUntilFunc to end the step but got %v/%v", result, userInput)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestRepeatUntilFuncMin(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	cartWF := newCartWorkflow("CART")
+	cartWF.RootStep.Repeat = NewRepeatConfig("Done", 2, 0)
+	cartWF.RootStep.RepeatUntilFunc = func(ui *UserInputs) bool { return true }
+	if err := wfc.AddWorkflow(&cartWF); err != nil {
+		t.Fatalf("Failed adding workflow. Error: %v", err)
+	}
+
+	runMessages(wfc, 1, "/CART", "Apple")
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Please add an item" {
+		t.Errorf("Expected the step to be repeated until the minimum is reached but got %s", reply)
+	}
+	runMessages(wfc, 1, "Pear")
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Please enter your address" {
+		t.Errorf("Expected RepeatUntilFunc to end the step at the minimum but got %s", reply)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestRepeatWithoutDoneButton(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	photos := NewWorkflowStep("Photos", "Photos", "Please send up to 3 photos", nil)
+	photos.InputKind = InputPhoto
+	photos.Repeat = NewRepeatConfig("", 0, 3)
+	done := NewWorkflowStep("Done", "", "Thank you", nil)
+	photos.Next = &done
+	photoWF := NewWorkflow("PhotoWF", "PHOTOS", &photos)
+	if err := wfc.AddWorkflow(&photoWF); err != nil {
+		t.Fatalf("Failed adding workflow. Error: %v", err)
+	}
+
+	runMessages(wfc, 1, "/PHOTOS")
+	var userInput *UserInputs
+	var result Result
+	for _, fileID := range []string{"p1", "p2", "p3"} {
+		photoMsg := mockBotMessage(1, "")
+		photoMsg.Photo = []tgbotapi.PhotoSize{{FileID: fileID}}
+		userInput, result, _ = wfc.ExecuteE(&photoMsg, mockSendFunc)
+		if fileID != "p3" && result != ResultInProgress {
+			t.Errorf("Expected the photo %s to be added but got %v", fileID, result)
+		}
+	}
+	if result != ResultCompleted || !reflect.DeepEqual(userInput.Lists["Photos"], []string{"p1", "p2", "p3"}) {
+		t.Errorf("Expected the 3 photos in the list but got %v/%v", result, userInput)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestDecodeList(t *testing.T) {
+	ui := UserInputs{
+		Data:       map[string]string{"Name": "Alice"},
+		Inputs:     map[string]InputValue{"Name": {Kind: InputText, Text: "Alice"}},
+		Lists:      map[string][]string{"Ages": {"30", "41"}, "Items": {"Apple", "Pear"}},
+		ListInputs: map[string][]InputValue{"Ages": {{Kind: InputText, Text: "30"}, {Kind: InputText, Text: "41"}}, "Items": {{Kind: InputText, Text: "Apple"}, {Kind: InputText, Text: "Pear"}}},
+	}
+	var registration struct {
+		Name  string
+		Ages  []int
+		Items []InputValue
+	}
+	if err := ui.Decode(&registration); err != nil {
+		t.Fatalf("Failed decoding. Error: %v", err)
+	}
+	if registration.Name != "Alice" || !reflect.DeepEqual(registration.Ages, []int{30, 41}) ||
+		len(registration.Items) != 2 || registration.Items[1].Text != "Pear" {
+		t.Errorf("Expected decoded lists but got %+v", registration)
+	}
+
+	ui.ListInputs["Ages"][1].Text = "many"
+	if err := ui.Decode(&registration); err == nil {
+		t.Error("Expected error decoding an invalid item")
+	}
+}
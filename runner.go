@@ -0,0 +1,162 @@
+package tbotworkflow
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Bot is the part of the Telegram Bot API used by the Runner.
+// *tgbotapi.BotAPI implements Bot.
+type Bot interface {
+	GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel
+	StopReceivingUpdates()
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+}
+
+// Runner receives the updates of the Bot using long polling, runs them through the workflows of the controller
+// and passes the UserInputs of the completed workflows to the OnComplete function of the workflow.
+type Runner struct {
+	// Controller running the workflows.
+	Controller *TBotWorkflowController
+	// Telegram Bot the updates are received from and the messages are sent with.
+	Bot Bot
+	// Config used to receive the updates. Defaults to all updates with a 60 seconds long polling timeout.
+	UpdateConfig tgbotapi.UpdateConfig
+	// Number of goroutines processing the updates. Defaults to 1.
+	// The updates of a chat are always processed by the same goroutine, in the order they were received.
+	Workers int
+	// Function called with the updates that failed, e.g. because a message could not be sent.
+	// Errors are written to the Logger of the controller if not set.
+	OnError func(update tgbotapi.Update, err error)
+	// Functions called with the UserInputs of the completed workflows, by Command.
+	onComplete map[string]func(ui *UserInputs)
+}
+
+// NewRunner returns a pointer to a Runner for the bot and the controller.
+// The controller answers the CallbackQuery of inline keyboards with the bot unless its RequestFunc is set.
+func NewRunner(bot Bot, wfc *TBotWorkflowController) *Runner {
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 60
+	if wfc.RequestFunc == nil && bot != nil {
+		wfc.RequestFunc = bot.Request
+	}
+
+	return &Runner{
+		Controller:   wfc,
+		Bot:          bot,
+		UpdateConfig: u,
+		onComplete:   make(map[string]func(ui *UserInputs)),
+	}
+}
+
+// AddWorkflow adds the workflow to the controller of the runner.
+// onComplete is called with the UserInputs every time a user completes the workflow. Can be nil.
+// With more than one of the Workers, onComplete can be called concurrently for different chats.
+// Returns the error of TBotWorkflowController.AddWorkflow.
+func (r *Runner) AddWorkflow(wf *TBotWorkflow, onComplete func(ui *UserInputs)) error {
+	if err := r.Controller.AddWorkflow(wf); err != nil {
+		return err
+	}
+	if onComplete != nil {
+		r.onComplete[wf.Command] = onComplete
+	}
+	return nil
+}
+
+// Run receives and processes the updates of the bot until ctx is done or the updates channel is closed.
+// Updates are processed by Workers goroutines. The updates already received when ctx is done
+// are completed before Run returns.
+// Returns nil once stopped.
+func (r *Runner) Run(ctx context.Context) error {
+	if r.Bot == nil || r.Controller == nil {
+		return fmt.Errorf("runner requires a Bot and a Controller")
+	}
+
+	updates := r.Bot.GetUpdatesChan(r.UpdateConfig)
+	defer r.Bot.StopReceivingUpdates()
+
+	workers := r.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	queues := make([]chan tgbotapi.Update, workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan tgbotapi.Update, 1)
+		wg.Add(1)
+		go func(queue chan tgbotapi.Update) {
+			defer wg.Done()
+			for update := range queue {
+				// Not ctx, so that the updates already received are completed on shutdown.
+				r.process(context.Background(), update, r.Bot.Send)
+			}
+		}(queues[i])
+	}
+	defer func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.Controller.Logger.Printf("Stopping runner. %v", ctx.Err())
+			return nil
+		case update, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			queue := queues[shard(update, workers)]
+			select {
+			case queue <- update:
+			case <-ctx.Done():
+				r.Controller.Logger.Printf("Stopping runner. %v", ctx.Err())
+				return nil
+			}
+		}
+	}
+}
+
+// shard returns the worker processing the updates of the chat of the update.
+func shard(update tgbotapi.Update, workers int) int {
+	var chatID int64
+	switch {
+	case update.Message != nil && update.Message.Chat != nil:
+		chatID = update.Message.Chat.ID
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil && update.CallbackQuery.Message.Chat != nil:
+		chatID = update.CallbackQuery.Message.Chat.ID
+	}
+	if chatID < 0 {
+		chatID = -chatID
+	}
+	return int(chatID % int64(workers))
+}
+
+// process runs the update through the workflows and dispatches the completed UserInputs.
+func (r *Runner) process(ctx context.Context, update tgbotapi.Update, send SendFunc) {
+	if update.Message == nil && update.CallbackQuery == nil {
+		return
+	}
+
+	userInputs, result, err := r.Controller.ExecuteUpdateContext(ctx, update, send)
+	if err != nil {
+		if r.OnError != nil {
+			r.OnError(update, err)
+		} else {
+			r.Controller.Logger.Printf("Failed processing update %d. Error: %v", update.UpdateID, err)
+		}
+	}
+
+	if result != ResultCompleted || userInputs == nil {
+		return
+	}
+	if onComplete, found := r.onComplete[userInputs.Command]; found {
+		onComplete(userInputs)
+	}
+}
@@ -0,0 +1,135 @@
+package tbotworkflow
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// fakeBot delivers the updates written to its channel and records the messages sent.
+type fakeBot struct {
+	updates  chan tgbotapi.Update
+	mu       sync.Mutex
+	sent     []tgbotapi.Chattable
+	requests []tgbotapi.Chattable
+	stopped  bool
+}
+
+func newFakeBot() *fakeBot {
+	return &fakeBot{updates: make(chan tgbotapi.Update)}
+}
+
+func (b *fakeBot) GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
+	return b.updates
+}
+
+func (b *fakeBot) StopReceivingUpdates() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stopped = true
+}
+
+func (b *fakeBot) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.sent = append(b.sent, c)
+	return tgbotapi.Message{}, nil
+}
+
+func (b *fakeBot) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.requests = append(b.requests, c)
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func TestRunner(t *testing.T) {
+	bot := newFakeBot()
+	runner := NewRunner(bot, NewWorkflowController("WFC"))
+
+	completed := make(chan *UserInputs, 2)
+	seqWF := newSeqWorkflow("CMD1")
+	if err := runner.AddWorkflow(&seqWF, func(ui *UserInputs) { completed <- ui }); err != nil {
+		t.Fatalf("Failed adding workflow. Error: %v", err)
+	}
+	condWF := newCondWorkflow("CMD2")
+	if err := runner.AddWorkflow(&condWF, nil); err != nil {
+		t.Fatalf("Failed adding workflow. Error: %v", err)
+	}
+	if err := runner.AddWorkflow(&seqWF, nil); err != nil {
+		t.Fatalf("Adding the same workflow again failed. Error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- runner.Run(ctx)
+	}()
+
+	cmd2 := mockBotCommand(2, "/CMD2")
+	cmd1 := mockBotCommand(1, "/CMD1")
+	step1 := mockBotMessage(1, "Step1Option1")
+	step2 := mockBotMessage(1, "Step2Option3")
+	channelPost := mockBotMessage(3, "Channel post")
+	channelPost.From = nil
+	updates := []tgbotapi.Update{
+		{UpdateID: 1},
+		{UpdateID: 2, EditedMessage: &step1},
+		{UpdateID: 3, Message: &channelPost},
+		{UpdateID: 4, Message: &cmd2},
+		{UpdateID: 5, Message: &cmd1},
+		{UpdateID: 6, Message: &step1},
+		{UpdateID: 7, Message: &step2},
+	}
+	for _, update := range updates {
+		bot.updates <- update
+	}
+
+	select {
+	case ui := <-completed:
+		if ui.Command != "CMD1" || ui.Data["K2"] != "Step2Option3" {
+			t.Errorf("Expected the UserInputs of CMD1 but got %v", ui)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("OnComplete was not called")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected Run to return nil on shutdown but got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop when the context was cancelled")
+	}
+
+	bot.mu.Lock()
+	defer bot.mu.Unlock()
+	if !bot.stopped {
+		t.Error("Expected Run to stop receiving updates")
+	}
+	// Replies to /CMD2, /CMD1, step 1 and step 2.
+	if len(bot.sent) != 4 {
+		t.Errorf("Expected 4 messages sent but got %d", len(bot.sent))
+	}
+}
+
+func TestExecuteWithoutMessage(t *testing.T) {
+	wfc := NewWorkflowController("WFC")
+	seqWF := newSeqWorkflow("CMD1")
+	mustAddWorkflow(t, wfc, &seqWF)
+
+	if userInput, done := wfc.Execute(nil, mockSendFunc); done || userInput != nil {
+		t.Error("Expected nil message to be ignored")
+	}
+
+	msg := mockBotCommand(1, "/CMD1")
+	msg.From = nil
+	if _, result, err := wfc.ExecuteE(&msg, mockSendFunc); result != ResultNotFound || err != nil {
+		t.Errorf("Expected message without sender to be ignored but got %v/%v", result, err)
+	}
+}
@@ -0,0 +1,290 @@
+package tbotworkflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SessionKey identifies the conversation a Session belongs to.
+// Sessions of chat scoped workflows are shared by all the members of the chat and have a zero UID.
+type SessionKey struct {
+	// Telegram Chat ID
+	ChatID int64
+	// Telegram User ID
+	UID int64
+}
+
+// String returns a representation of the key that is safe to use as a file name.
+func (k SessionKey) String() string {
+	return fmt.Sprintf("%d_%d", k.ChatID, k.UID)
+}
+
+// Session is the persisted progress of a user through a workflow.
+// Steps are referenced by their ID so that a Session can be rehydrated
+// against the workflows registered on the TBotWorkflowController.
+type Session struct {
+	// Key of the conversation.
+	Key SessionKey
+	// Telegram Chat ID the workflow is running in.
+	ChatID int64
+	// Name of the workflow being executed.
+	WorkflowName string
+	// Command for which the workflow was triggered.
+	Command string
+	// ID of the step the user is currently at.
+	StepID string
+	// User inputs captured so far.
+	UserInputs UserInputs
+	// IDs of the steps visited before the current step, oldest first.
+	History []string
+	// Sub-workflows being run, outermost first. StepID and History are those of the innermost sub-workflow.
+	Frames []SessionFrame
+	// ID of the summary step the user returns to once the current step is answered.
+	// Empty unless the user is editing an input from a summary step.
+	SummaryStepID string
+	// Time the user entered the current step.
+	StepEnteredAt time.Time
+	// Time of the last message processed for this session.
+	LastActive time.Time
+	// Time after which the session is discarded due to inactivity.
+	// Zero means the session never expires.
+	ExpiresAt time.Time
+}
+
+// SessionFrame is a sub-workflow being run from a step of the parent workflow.
+type SessionFrame struct {
+	// Command of the sub-workflow.
+	Command string
+	// ID of the step of the parent workflow running the sub-workflow.
+	StepID string
+	// IDs of the steps of the parent workflow visited before the step running the sub-workflow.
+	History []string
+	// Prefix of the Data and Inputs keys of the sub-workflow.
+	KeyPrefix string
+	// ID of the summary step of the parent workflow the user returns to once the sub-workflow is completed.
+	// Empty unless the user is editing the inputs of the sub-workflow from a summary step.
+	SummaryStepID string
+}
+
+// Expired reports whether the session has been inactive past its idle timeout.
+func (s *Session) Expired(now time.Time) bool {
+	return !s.ExpiresAt.IsZero() && !now.Before(s.ExpiresAt)
+}
+
+func (s *Session) clone() *Session {
+	c := *s
+	c.UserInputs.Data = make(map[string]string, len(s.UserInputs.Data))
+	for k, v := range s.UserInputs.Data {
+		c.UserInputs.Data[k] = v
+	}
+	if s.UserInputs.Inputs != nil {
+		c.UserInputs.Inputs = make(map[string]InputValue, len(s.UserInputs.Inputs))
+		for k, v := range s.UserInputs.Inputs {
+			c.UserInputs.Inputs[k] = v
+		}
+	}
+	if s.UserInputs.Lists != nil {
+		c.UserInputs.Lists = make(map[string][]string, len(s.UserInputs.Lists))
+		for k, v := range s.UserInputs.Lists {
+			c.UserInputs.Lists[k] = append([]string(nil), v...)
+		}
+	}
+	if s.UserInputs.ListInputs != nil {
+		c.UserInputs.ListInputs = make(map[string][]InputValue, len(s.UserInputs.ListInputs))
+		for k, v := range s.UserInputs.ListInputs {
+			c.UserInputs.ListInputs[k] = append([]InputValue(nil), v...)
+		}
+	}
+	c.History = append([]string(nil), s.History...)
+	if s.Frames != nil {
+		c.Frames = make([]SessionFrame, len(s.Frames))
+		for i, frame := range s.Frames {
+			frame.History = append([]string(nil), frame.History...)
+			c.Frames[i] = frame
+		}
+	}
+	return &c
+}
+
+// SessionStore persists the workflow progress of all the users.
+type SessionStore interface {
+	// Get returns the session for the given key.
+	// bool = false means there is no session for the key.
+	Get(key SessionKey) (*Session, bool, error)
+	// Put creates or replaces the session for the given key.
+	Put(key SessionKey, session *Session) error
+	// Delete removes the session for the given key.
+	// Deleting a key without a session is not an error.
+	Delete(key SessionKey) error
+	// List returns all the stored sessions.
+	List() ([]*Session, error)
+}
+
+// MemorySessionStore keeps the sessions in memory.
+// It is the default SessionStore of the TBotWorkflowController.
+// All sessions are lost when the process exits.
+type MemorySessionStore struct {
+	sessions map[SessionKey]*Session
+	m        sync.Mutex
+}
+
+// NewMemorySessionStore returns a pointer to a new MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[SessionKey]*Session)}
+}
+
+// Get returns a copy of the session stored for the key.
+func (s *MemorySessionStore) Get(key SessionKey) (*Session, bool, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	session, ok := s.sessions[key]
+	if !ok {
+		return nil, false, nil
+	}
+	return session.clone(), true, nil
+}
+
+// Put stores a copy of the session for the key.
+func (s *MemorySessionStore) Put(key SessionKey, session *Session) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	if s.sessions == nil {
+		s.sessions = make(map[SessionKey]*Session)
+	}
+	s.sessions[key] = session.clone()
+	return nil
+}
+
+// Delete removes the session stored for the key.
+func (s *MemorySessionStore) Delete(key SessionKey) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+	delete(s.sessions, key)
+	return nil
+}
+
+// List returns a copy of all the sessions.
+func (s *MemorySessionStore) List() ([]*Session, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session.clone())
+	}
+	return sessions, nil
+}
+
+// FileSessionStore keeps each session as a JSON file in a directory,
+// so that the workflow progress survives restarts of the bot.
+type FileSessionStore struct {
+	dir string
+	m   sync.Mutex
+}
+
+// NewFileSessionStore returns a pointer to a FileSessionStore writing to dir.
+// The directory is created if it does not exist.
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed creating session dir %s: %w", dir, err)
+	}
+	return &FileSessionStore{dir: dir}, nil
+}
+
+// Get reads the session stored for the key.
+func (s *FileSessionStore) Get(key SessionKey) (*Session, bool, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	b, err := ioutil.ReadFile(s.path(key))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed reading session %s: %w", key, err)
+	}
+
+	session := &Session{}
+	if err := json.Unmarshal(b, session); err != nil {
+		return nil, false, fmt.Errorf("failed decoding session %s: %w", key, err)
+	}
+	return session, true, nil
+}
+
+// Put writes the session for the key.
+// The file is replaced atomically so a crash never leaves a partial session behind.
+func (s *FileSessionStore) Put(key SessionKey, session *Session) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	b, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed encoding session %s: %w", key, err)
+	}
+
+	tmp, err := ioutil.TempFile(s.dir, key.String()+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed writing session %s: %w", key, err)
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed writing session %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed writing session %s: %w", key, err)
+	}
+	if err := os.Rename(tmp.Name(), s.path(key)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed writing session %s: %w", key, err)
+	}
+	return nil
+}
+
+// Delete removes the session file for the key.
+func (s *FileSessionStore) Delete(key SessionKey) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed deleting session %s: %w", key, err)
+	}
+	return nil
+}
+
+// List reads all the sessions in the directory.
+func (s *FileSessionStore) List() ([]*Session, error) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed listing sessions: %w", err)
+	}
+
+	sessions := make([]*Session, 0, len(paths))
+	for _, path := range paths {
+		b, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed reading session file %s: %w", path, err)
+		}
+		session := &Session{}
+		if err := json.Unmarshal(b, session); err != nil {
+			return nil, fmt.Errorf("failed decoding session file %s: %w", path, err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+func (s *FileSessionStore) path(key SessionKey) string {
+	return filepath.Join(s.dir, key.String()+".json")
+}
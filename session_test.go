@@ -0,0 +1,192 @@
+package tbotworkflow
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestFileSessionStore(t *testing.T) {
+	store, err := NewFileSessionStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed creating FileSessionStore. Error: %v", err)
+	}
+
+	key := SessionKey{UID: 1234}
+	if _, found, err := store.Get(key); found || err != nil {
+		t.Errorf("Expected no session but got found: %v, error: %v", found, err)
+	}
+
+	session := &Session{
+		Key:          key,
+		WorkflowName: "WF",
+		Command:      "CMD1",
+		StepID:       "Step2",
+		UserInputs:   UserInputs{UID: 1234, Command: "CMD1", Data: map[string]string{"K1": "Step1Option1"}},
+	}
+	if err := store.Put(key, session); err != nil {
+		t.Fatalf("Failed saving session. Error: %v", err)
+	}
+
+	got, found, err := store.Get(key)
+	if !found || err != nil {
+		t.Fatalf("Expected session but got found: %v, error: %v", found, err)
+	}
+	if got.StepID != "Step2" || got.UserInputs.Data["K1"] != "Step1Option1" {
+		t.Errorf("Expected StepID: Step2, K1: Step1Option1 but got StepID: %s, K1: %s",
+			got.StepID, got.UserInputs.Data["K1"])
+	}
+
+	if err := store.Delete(key); err != nil {
+		t.Errorf("Failed deleting session. Error: %v", err)
+	}
+	if _, found, _ := store.Get(key); found {
+		t.Error("Expected session to be deleted but it was found")
+	}
+	if err := store.Delete(key); err != nil {
+		t.Errorf("Deleting a missing session should not fail. Error: %v", err)
+	}
+}
+
+func TestSessionSurvivesRestart(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	dir := t.TempDir()
+
+	newController := func() *TBotWorkflowController {
+		store, err := NewFileSessionStore(dir)
+		if err != nil {
+			t.Fatalf("Failed creating FileSessionStore. Error: %v", err)
+		}
+		wfc := NewWorkflowController("WFC")
+		wfc.SetSessionStore(store)
+		condWF := newCondWorkflow("CMD2")
+		mustAddWorkflow(t, wfc, &condWF)
+		return wfc
+	}
+
+	botInteractions := getCondBotInteractions()
+	wfc := newController()
+	for _, bi := range botInteractions[:2] {
+		wfc.Execute(&bi.botMsg, mockSendFunc)
+	}
+
+	// A new controller with fresh workflow pointers picks up where the user left off.
+	wfc = newController()
+	var userInput *UserInputs
+	var done bool
+	for _, bi := range botInteractions[2:] {
+		userInput, done = wfc.Execute(&bi.botMsg, mockSendFunc)
+	}
+
+	if !done || userInput == nil {
+		t.Fatal("Expected workflow to complete after restart")
+	}
+	if len(userInput.Data) != 3 {
+		t.Errorf("Expected 3 user inputs but got %d", len(userInput.Data))
+	}
+	if userInput.Data["K1"] != "Step1Option2" {
+		t.Errorf("Expected K1: Step1Option2 but got %s", userInput.Data["K1"])
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestGroupChatSessions(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	seqWF := newSeqWorkflow("CMD1")
+	condWF := newCondWorkflow("CMD2")
+	condWF.ChatScoped = true
+	mustAddWorkflow(t, wfc, &seqWF)
+	mustAddWorkflow(t, wfc, &condWF)
+
+	// Same user running the workflow in a group and in a private chat.
+	for _, msg := range []tgbotapi.Message{
+		mockGroupMessage(-100, 1234, "/CMD1"),
+		mockGroupMessage(1234, 1234, "/CMD1"),
+		mockGroupMessage(-100, 1234, "Step1Option1"),
+		mockGroupMessage(1234, 1234, "Step1Option2"),
+	} {
+		wfc.Execute(&msg, mockSendFunc)
+	}
+
+	groupMsg := mockGroupMessage(-100, 1234, "Step2Option3")
+	userInput, done := wfc.Execute(&groupMsg, mockSendFunc)
+	if !done || userInput == nil {
+		t.Fatal("Expected workflow in the group chat to complete")
+	}
+	if userInput.Data["K1"] != "Step1Option1" || userInput.ChatID != -100 || userInput.ChatType != "group" {
+		t.Errorf("Expected K1: Step1Option1 in group chat -100 but got K1: %s in %s chat %d",
+			userInput.Data["K1"], userInput.ChatType, userInput.ChatID)
+	}
+
+	privateMsg := mockGroupMessage(1234, 1234, "Step2Option1")
+	userInput, done = wfc.Execute(&privateMsg, mockSendFunc)
+	if !done || userInput == nil {
+		t.Fatal("Expected workflow in the private chat to complete")
+	}
+	if userInput.Data["K1"] != "Step1Option2" || userInput.ChatID != 1234 {
+		t.Errorf("Expected K1: Step1Option2 in chat 1234 but got K1: %s in chat %d", userInput.Data["K1"], userInput.ChatID)
+	}
+
+	// Chat scoped workflow advanced by different members of the group.
+	for _, msg := range []tgbotapi.Message{
+		mockGroupMessage(-100, 1, "/CMD2"),
+		mockGroupMessage(-100, 2, "Step1Option2"),
+		mockGroupMessage(-100, 3, "Step2Condition1"),
+	} {
+		wfc.Execute(&msg, mockSendFunc)
+	}
+	lastMsg := mockGroupMessage(-100, 1, "C1Step3Option1")
+	userInput, done = wfc.Execute(&lastMsg, mockSendFunc)
+	if !done || userInput == nil {
+		t.Fatal("Expected chat scoped workflow to complete")
+	}
+	if userInput.UID != 1 || len(userInput.Data) != 3 {
+		t.Errorf("Expected 3 inputs for workflow started by user 1 but got %d inputs for user %d",
+			len(userInput.Data), userInput.UID)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func mockGroupMessage(chatID int64, uid int64, text string) tgbotapi.Message {
+	msg := mockBotMessage(chatID, text)
+	if text[0] == '/' {
+		msg = mockBotCommand(chatID, text)
+	}
+	msg.From.ID = uid
+	msg.Chat.Type = "private"
+	if chatID < 0 {
+		msg.Chat.Type = "group"
+	}
+	return msg
+}
+
+func TestSessionSameNamedSteps(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	choice := NewWorkflowStep("Choice", "Choice", "A or B?", nil)
+	choice.ConditionFunc = func(msg *tgbotapi.Message) string { return msg.Text }
+	confirmA := NewWorkflowStep("Confirm", "ConfirmA", "Confirm A?", nil)
+	confirmB := NewWorkflowStep("Confirm", "ConfirmB", "Confirm B?", nil)
+	doneA := NewWorkflowStep("DoneA", "", "Done with A", nil)
+	doneB := NewWorkflowStep("DoneB", "", "Done with B", nil)
+	choice.ConditionalNext["A"] = &confirmA
+	choice.ConditionalNext["B"] = &confirmB
+	confirmA.Next = &doneA
+	confirmB.Next = &doneB
+	wf := NewWorkflow("WF", "CMD1", &choice)
+	if err := wfc.AddWorkflow(&wf); err != nil {
+		t.Fatalf("Failed adding workflow. Error: %v", err)
+	}
+
+	runMessages(wfc, 1, "/CMD1", "B")
+	msg := mockBotMessage(1, "")
+	if session := wfc.currentSession(&msg); session == nil || session.StepID != "Confirm#2" {
+		t.Errorf("Expected a unique default ID for the second Confirm step but got %+v", session)
+	}
+	userInput, result, _ := runMessages(wfc, 1, "Yes")
+	if result != ResultCompleted || userInput.Data["ConfirmB"] != "Yes" || sentMsgs[len(sentMsgs)-1].Text != "Done with B" {
+		t.Errorf("Expected the workflow to continue on the B branch but got %v/%v", result, userInput)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
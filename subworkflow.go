@@ -0,0 +1,209 @@
+package tbotworkflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// workflowFrame is a sub-workflow the user is running from a step of the parent workflow.
+type workflowFrame struct {
+	// The sub-workflow.
+	wf *TBotWorkflow
+	// Step of the parent workflow running the sub-workflow.
+	callStep *TBotWorkflowStep
+	// History of the parent workflow when the sub-workflow was entered.
+	history []string
+	// Prefix of the Data and Inputs keys of the sub-workflow.
+	keyPrefix string
+	// ID of the summary step of the parent workflow to return to, if the sub-workflow is edited.
+	summaryStepID string
+}
+
+// current returns the workflow the CurrentStep belongs to.
+func (t *workflowTracker) current() *TBotWorkflow {
+	if len(t.frames) == 0 {
+		return t.wf
+	}
+	return t.frames[len(t.frames)-1].wf
+}
+
+// dataKey returns the key the input of a step of the current workflow is stored with.
+func (t *workflowTracker) dataKey(key string) string {
+	if len(t.frames) == 0 {
+		return key
+	}
+	return t.frames[len(t.frames)-1].keyPrefix + key
+}
+
+// running tells if the user is already running the workflow, as the root workflow or a sub-workflow.
+func (t *workflowTracker) running(wf *TBotWorkflow) bool {
+	if t.wf == wf {
+		return true
+	}
+	for _, frame := range t.frames {
+		if frame.wf == wf {
+			return true
+		}
+	}
+	return false
+}
+
+// enter enters the CurrentStep. Steps with a SubWorkflow run the sub-workflow from its RootStep,
+// and the last step of a sub-workflow returns to the step following the SubWorkflow step of the parent.
+// Returns an error wrapping ErrBrokenWorkflow if the next step cannot be determined.
+func (w *TBotWorkflowController) enter(ctx context.Context, t *workflowTracker, msg *tgbotapi.Message) error {
+	for {
+		step := t.CurrentStep
+		if step.SubWorkflow != "" {
+			w.onStepEnter(t, msg)
+			sub, found := w.workflows[strings.ToUpper(step.SubWorkflow)]
+			if !found {
+				return fmt.Errorf("%w: Workflow: %s Step: %s runs SubWorkflow: %s which is not registered",
+					ErrBrokenWorkflow, t.current().Name, step.Name, step.SubWorkflow)
+			}
+			if t.running(sub) {
+				return fmt.Errorf("%w: Workflow: %s Step: %s runs SubWorkflow: %s recursively",
+					ErrBrokenWorkflow, t.current().Name, step.Name, step.SubWorkflow)
+			}
+
+			w.Logger.Printf("Step: %s, Entering SubWorkflow: %s", step.Name, sub.Name)
+			t.frames = append(t.frames, workflowFrame{wf: sub, callStep: step, history: t.history,
+				keyPrefix: t.subKeyPrefix(step), summaryStepID: t.summaryStepID})
+			t.history = nil
+			t.summaryStepID = ""
+			t.CurrentStep = sub.RootStep
+			callHooks(&t.userInputs, t.CurrentStep, msg, sub.Hooks.OnStart)
+			continue
+		}
+
+		if len(t.frames) > 0 && step.isLastStep() {
+			frame := t.frames[len(t.frames)-1]
+			w.Logger.Printf("SubWorkflow: %s completed, Returning to Step: %s", frame.wf.Name, frame.callStep.Name)
+			callHooks(&t.userInputs, step, msg, frame.wf.Hooks.OnComplete)
+			t.frames = t.frames[:len(t.frames)-1]
+			t.history = frame.history
+			t.CurrentStep = frame.callStep
+			w.onStepExit(t, msg)
+
+			if frame.summaryStepID != "" {
+				if summary := t.current().FindStep(frame.summaryStepID); summary != nil {
+					// The edited sub-workflow returns to the summary step.
+					w.recordTransition(t, msg, frame.callStep, summary, TransitionSummary, "")
+					t.CurrentStep = summary
+					continue
+				}
+			}
+			if frame.callStep.isLastStep() {
+				// The last step of the sub-workflow ends the parent as well.
+				t.CurrentStep = step
+				continue
+			}
+			next, err := w.nextStep(ctx, t, msg)
+			if err != nil {
+				return err
+			}
+			t.history = append(t.history, frame.callStep.id())
+			t.CurrentStep = next
+			continue
+		}
+
+		w.onStepEnter(t, msg)
+		return nil
+	}
+}
+
+// nextStep returns the step following the CurrentStep given the message from the user.
+// Returns an error wrapping ErrBrokenWorkflow if the ConditionFunc output has no ConditionalNext step.
+func (w *TBotWorkflowController) nextStep(ctx context.Context, t *workflowTracker, msg *tgbotapi.Message) (*TBotWorkflowStep, error) {
+	step := t.CurrentStep
+	if !step.hasCondition() {
+		w.recordTransition(t, msg, step, step.Next, TransitionNext, "")
+		return step.Next, nil
+	}
+	cond, err := step.condition(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	next := step.ConditionalNext[cond]
+	if next == nil {
+		return nil, fmt.Errorf("%w: Workflow: %s cannot determine next step for Step: %s",
+			ErrBrokenWorkflow, t.current().Name, step.Name)
+	}
+	w.recordTransition(t, msg, step, next, TransitionConditionalNext, cond)
+	return next, nil
+}
+
+// deleteSubInputs removes the inputs captured by the sub-workflow run by the step.
+func (w *TBotWorkflowController) deleteSubInputs(t *workflowTracker, step *TBotWorkflowStep) {
+	w.deleteWorkflowInputs(t, step.SubWorkflow, t.subKeyPrefix(step), make(map[string]bool))
+}
+
+// subKeyPrefix returns the prefix of the Data and Inputs keys of the sub-workflow run by the step.
+func (t *workflowTracker) subKeyPrefix(step *TBotWorkflowStep) string {
+	keyPrefix := t.dataKey("")
+	if step.Key != "" {
+		keyPrefix += step.Key + "."
+	}
+	return keyPrefix
+}
+
+func (w *TBotWorkflowController) deleteWorkflowInputs(t *workflowTracker, command string, keyPrefix string, visited map[string]bool) {
+	wf, found := w.workflows[strings.ToUpper(command)]
+	if !found || visited[wf.Command] {
+		return
+	}
+	visited[wf.Command] = true
+	defer delete(visited, wf.Command)
+	for _, step := range wf.steps() {
+		if step.SubWorkflow != "" {
+			subPrefix := keyPrefix
+			if step.Key != "" {
+				subPrefix += step.Key + "."
+			}
+			w.deleteWorkflowInputs(t, step.SubWorkflow, subPrefix, visited)
+		} else if step.Key != "" {
+			delete(t.userInputs.Data, keyPrefix+step.Key)
+			delete(t.userInputs.Inputs, keyPrefix+step.Key)
+			t.deleteItems(keyPrefix + step.Key)
+		}
+	}
+}
+
+// sessionFrames returns the sub-workflows of the tracker to be persisted in the Session.
+func (t *workflowTracker) sessionFrames() []SessionFrame {
+	if len(t.frames) == 0 {
+		return nil
+	}
+	frames := make([]SessionFrame, 0, len(t.frames))
+	for _, frame := range t.frames {
+		frames = append(frames, SessionFrame{
+			Command:       frame.wf.Command,
+			StepID:        frame.callStep.id(),
+			History:       frame.history,
+			KeyPrefix:     frame.keyPrefix,
+			SummaryStepID: frame.summaryStepID,
+		})
+	}
+	return frames
+}
+
+// workflowFrames rehydrates the sub-workflows of the session against the registered workflows.
+// Returns false if one of the workflows or steps no longer exists.
+func (w *TBotWorkflowController) workflowFrames(wf *TBotWorkflow, sessionFrames []SessionFrame) ([]workflowFrame, bool) {
+	frames := make([]workflowFrame, 0, len(sessionFrames))
+	parent := wf
+	for _, sf := range sessionFrames {
+		callStep := parent.FindStep(sf.StepID)
+		sub, found := w.workflows[sf.Command]
+		if callStep == nil || !found {
+			return nil, false
+		}
+		frames = append(frames, workflowFrame{wf: sub, callStep: callStep, history: sf.History,
+			keyPrefix: sf.KeyPrefix, summaryStepID: sf.SummaryStepID})
+		parent = sub
+	}
+	return frames, true
+}
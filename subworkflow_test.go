@@ -0,0 +1,203 @@
+package tbotworkflow
+
+import (
+	"errors"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// newAddressWorkflow returns a workflow capturing a Street and a City.
+func newAddressWorkflow(cmd string) TBotWorkflow {
+	street := NewWorkflowStep("Street", "Street", "Please enter the street", nil)
+	city := NewWorkflowStep("City", "City", "Please enter the city", nil)
+	done := NewWorkflowStep("AddressDone", "", "Address saved", nil)
+	street.Next = &city
+	city.Next = &done
+	return NewWorkflow("AddressWF", cmd, &street)
+}
+
+// newOrderWorkflow returns a workflow running the ADDRESS workflow with the given key between the Product and Notes steps.
+func newOrderWorkflow(cmd string, addressKey string) TBotWorkflow {
+	productKB := getSingleButtonKeyboard("Product1")
+	product := NewWorkflowStep("Product", "Product", "Please select a product", &productKB)
+	shipTo := NewWorkflowStep("ShipTo", addressKey, "", nil)
+	shipTo.SubWorkflow = "ADDRESS"
+	notes := NewWorkflowStep("Notes", "Notes", "Any notes?", nil)
+	confirm := NewWorkflowStep("Confirm", "", "Thanks for your order", nil)
+	product.Next = &shipTo
+	shipTo.Next = &notes
+	notes.Next = &confirm
+
+	wf := NewWorkflow("OrderWF", cmd, &product)
+	wf.CancelButtonConfig = NewCancelButtonConfig("RESET", "Clearing input. Please start again")
+	wf.BackButtonConfig = NewBackButtonConfig("BACK")
+	return wf
+}
+
+func runMessages(wfc *TBotWorkflowController, chatID int64, texts ...string) (*UserInputs, Result, error) {
+	var userInput *UserInputs
+	var result Result
+	var err error
+	for _, text := range texts {
+		msg := mockBotMessage(chatID, text)
+		if text[0] == '/' {
+			msg = mockBotCommand(chatID, text)
+		}
+		userInput, result, err = wfc.ExecuteE(&msg, mockSendFunc)
+	}
+	return userInput, result, err
+}
+
+func TestSubWorkflow(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	addressWF := newAddressWorkflow("ADDRESS")
+	var subEvents []string
+	addressWF.Hooks.OnStart = func(ui *UserInputs, step *TBotWorkflowStep, msg *tgbotapi.Message) {
+		subEvents = append(subEvents, "start:"+step.Name)
+	}
+	addressWF.Hooks.OnComplete = func(ui *UserInputs, step *TBotWorkflowStep, msg *tgbotapi.Message) {
+		subEvents = append(subEvents, "complete:"+ui.Data["Shipping.City"])
+	}
+	orderWF := newOrderWorkflow("ORDER", "Shipping")
+	mustAddWorkflow(t, wfc, &addressWF)
+	mustAddWorkflow(t, wfc, &orderWF)
+
+	runMessages(wfc, 1, "/ORDER", "Product1")
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Please enter the street" {
+		t.Errorf("Expected the first step of the sub-workflow but got %s", reply)
+	}
+	msg := mockBotMessage(1, "")
+	if session := wfc.currentSession(&msg); session == nil || session.Command != "ORDER" || len(session.Frames) != 1 ||
+		session.Frames[0].Command != "ADDRESS" || session.Frames[0].StepID != "ShipTo" || session.StepID != "Street" {
+		t.Errorf("Expected session in the sub-workflow but got %+v", session)
+	}
+
+	runMessages(wfc, 1, "Main St", "Paris")
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Any notes?" {
+		t.Errorf("Expected to return to the Next step of the parent but got %s", reply)
+	}
+	userInput, result, err := runMessages(wfc, 1, "Leave at the door")
+	if result != ResultCompleted || err != nil {
+		t.Fatalf("Expected completed workflow but got %v/%v", result, err)
+	}
+	expected := map[string]string{"Product": "Product1", "Shipping.Street": "Main St", "Shipping.City": "Paris", "Notes": "Leave at the door"}
+	for key, value := range expected {
+		if userInput.Data[key] != value {
+			t.Errorf("Expected %s=%s but got %s", key, value, userInput.Data[key])
+		}
+	}
+	if userInput.Command != "ORDER" {
+		t.Errorf("Expected the Command of the parent but got %s", userInput.Command)
+	}
+	if len(subEvents) != 2 || subEvents[0] != "start:Street" || subEvents[1] != "complete:Paris" {
+		t.Errorf("Expected the hooks of the sub-workflow but got %v", subEvents)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestSubWorkflowMerge(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	addressWF := newAddressWorkflow("ADDRESS")
+	orderWF := newOrderWorkflow("ORDER", "")
+	mustAddWorkflow(t, wfc, &addressWF)
+	mustAddWorkflow(t, wfc, &orderWF)
+
+	userInput, result, _ := runMessages(wfc, 1, "/ORDER", "Product1", "Main St", "Paris", "None")
+	if result != ResultCompleted || userInput.Data["City"] != "Paris" || userInput.Data["Street"] != "Main St" {
+		t.Errorf("Expected the inputs of the sub-workflow merged but got %v/%v", result, userInput)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestSubWorkflowCancelAndBack(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	addressWF := newAddressWorkflow("ADDRESS")
+	orderWF := newOrderWorkflow("ORDER", "Shipping")
+	cancelled := ""
+	orderWF.Hooks.OnCancel = func(ui *UserInputs, step *TBotWorkflowStep, msg *tgbotapi.Message) {
+		cancelled = step.Name
+	}
+	mustAddWorkflow(t, wfc, &addressWF)
+	mustAddWorkflow(t, wfc, &orderWF)
+
+	// Cancel in the sub-workflow cancels the parent.
+	_, result, _ := runMessages(wfc, 1, "/ORDER", "Product1", "Main St", "RESET")
+	if result != ResultCancelled || cancelled != "City" {
+		t.Errorf("Expected the parent cancelled at City but got %v/%s", result, cancelled)
+	}
+	msg := mockBotMessage(1, "")
+	if session := wfc.currentSession(&msg); session != nil {
+		t.Errorf("Expected no session after cancel but got %+v", session)
+	}
+
+	// Back at the first step of the sub-workflow repeats it.
+	runMessages(wfc, 1, "/ORDER", "Product1", "BACK")
+	if session := wfc.currentSession(&msg); session == nil || session.StepID != "Street" {
+		t.Errorf("Expected to stay at Street but got %+v", session)
+	}
+
+	// Back to the step running the sub-workflow runs it again.
+	runMessages(wfc, 1, "Main St", "Paris", "BACK")
+	session := wfc.currentSession(&msg)
+	if session == nil || session.StepID != "Street" || len(session.Frames) != 1 {
+		t.Fatalf("Expected to run the sub-workflow again but got %+v", session)
+	}
+	if _, found := session.UserInputs.Data["Shipping.City"]; found {
+		t.Errorf("Expected the inputs of the sub-workflow removed but got %v", session.UserInputs.Data)
+	}
+	if session.UserInputs.Data["Product"] != "Product1" {
+		t.Errorf("Expected the inputs of the parent kept but got %v", session.UserInputs.Data)
+	}
+
+	userInput, result, _ := runMessages(wfc, 1, "High St", "London", "None")
+	if result != ResultCompleted || userInput.Data["Shipping.City"] != "London" {
+		t.Errorf("Expected completed workflow with the new address but got %v/%v", result, userInput)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestSubWorkflowLastStep(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	addressWF := newAddressWorkflow("ADDRESS")
+	orderWF := newOrderWorkflow("ORDER", "Shipping")
+	orderWF.RootStep.Next.Next = nil
+	mustAddWorkflow(t, wfc, &addressWF)
+	mustAddWorkflow(t, wfc, &orderWF)
+
+	userInput, result, _ := runMessages(wfc, 1, "/ORDER", "Product1", "Main St", "Paris")
+	if result != ResultCompleted || userInput.Data["Shipping.City"] != "Paris" {
+		t.Errorf("Expected the sub-workflow to end the parent but got %v/%v", result, userInput)
+	}
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Address saved" {
+		t.Errorf("Expected the last step of the sub-workflow but got %s", reply)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestSubWorkflowNotRegistered(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	orderWF := newOrderWorkflow("ORDER", "Shipping")
+	mustAddWorkflow(t, wfc, &orderWF)
+
+	_, result, err := runMessages(wfc, 1, "/ORDER", "Product1")
+	if result != ResultBroken || !errors.Is(err, ErrBrokenWorkflow) {
+		t.Errorf("Expected broken workflow but got %v/%v", result, err)
+	}
+
+	// A workflow running itself is broken as well.
+	wfc = NewWorkflowController("WFC")
+	addressWF := newAddressWorkflow("ADDRESS")
+	addressWF.RootStep.Next.SubWorkflow = "ADDRESS"
+	mustAddWorkflow(t, wfc, &addressWF)
+	_, result, err = runMessages(wfc, 1, "/ADDRESS", "Main St")
+	if result != ResultBroken || !errors.Is(err, ErrBrokenWorkflow) {
+		t.Errorf("Expected broken workflow for recursive sub-workflow but got %v/%v", result, err)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
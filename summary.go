@@ -0,0 +1,223 @@
+package tbotworkflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// SummaryConfig will tell the workflow to show the inputs of the user for review
+// and let the user edit any of them before moving on.
+type SummaryConfig struct {
+	confirmButtonText string
+	editButtonText    string
+}
+
+// NewSummaryConfig returns a pointer to summary config.
+// confirmButtonText: Tells the workflow step what is the confirm buttons text. E.g. "Confirm", "Submit" etc.
+// When the user presses the Confirm button, the workflow moves on to the next step.
+// editButtonText: Format of the edit buttons text with the step name, e.g. "Edit %s".
+// When the user presses an Edit button, the workflow goes back to that step and returns to the summary once answered.
+func NewSummaryConfig(confirmButtonText string, editButtonText string) *SummaryConfig {
+	return &SummaryConfig{
+		confirmButtonText: confirmButtonText,
+		editButtonText:    editButtonText,
+	}
+}
+
+// answeredSteps returns the steps of the current workflow answered by the user, in the order they were visited.
+// SubWorkflow steps are included if the sub-workflow captured inputs. Steps without a Key are left out.
+func (w *TBotWorkflowController) answeredSteps(t *workflowTracker) []*TBotWorkflowStep {
+	steps := []*TBotWorkflowStep{}
+	seen := make(map[string]bool)
+	for _, id := range t.history {
+		step := t.current().FindStep(id)
+		if step == nil || seen[id] || step.Summary != nil {
+			continue
+		}
+		seen[id] = true
+		if step.SubWorkflow != "" {
+			if len(w.subInputLines(t, step, "")) > 0 {
+				steps = append(steps, step)
+			}
+		} else if step.Key == "" {
+			continue
+		} else if _, found := t.userInputs.Data[t.dataKey(step.Key)]; found {
+			steps = append(steps, step)
+		} else if _, found := t.userInputs.Lists[t.dataKey(step.Key)]; found {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// summaryText returns the text of the summary step followed by a line with the name and input of each answered step.
+// The inputs of a sub-workflow are listed with the names of its steps.
+func (w *TBotWorkflowController) summaryText(t *workflowTracker, lang string) string {
+	lines := []string{}
+	if text := w.translate(lang, t.CurrentStep.ReplyText); text != "" {
+		lines = append(lines, text, "")
+	}
+	for _, step := range w.answeredSteps(t) {
+		if step.SubWorkflow != "" {
+			lines = append(lines, w.subInputLines(t, step, lang)...)
+			continue
+		}
+		lines = append(lines, w.inputLine(t.userInputs, step, t.dataKey(step.Key), lang))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// inputLine returns the name of the step and the input stored with the key.
+func (w *TBotWorkflowController) inputLine(ui UserInputs, step *TBotWorkflowStep, key string, lang string) string {
+	value := ui.Data[key]
+	if step.Repeat != nil {
+		value = strings.Join(ui.Lists[key], ", ")
+	}
+	return fmt.Sprintf("%s: %s", w.translate(lang, step.Name), tgbotapi.EscapeText(w.parseMode, value))
+}
+
+// subInputLines returns a line per input captured by the sub-workflow run by the step,
+// in the order of the steps of the sub-workflow.
+func (w *TBotWorkflowController) subInputLines(t *workflowTracker, step *TBotWorkflowStep, lang string) []string {
+	return w.workflowInputLines(t, step.SubWorkflow, t.subKeyPrefix(step), lang, make(map[string]bool))
+}
+
+func (w *TBotWorkflowController) workflowInputLines(t *workflowTracker, command string, keyPrefix string, lang string,
+	visited map[string]bool) []string {
+	lines := []string{}
+	wf, found := w.workflows[strings.ToUpper(command)]
+	if !found || visited[wf.Command] {
+		return lines
+	}
+	visited[wf.Command] = true
+	defer delete(visited, wf.Command)
+	for _, step := range wf.steps() {
+		if step.SubWorkflow != "" {
+			subPrefix := keyPrefix
+			if step.Key != "" {
+				subPrefix += step.Key + "."
+			}
+			lines = append(lines, w.workflowInputLines(t, step.SubWorkflow, subPrefix, lang, visited)...)
+			continue
+		}
+		if step.Key == "" || step.Summary != nil {
+			continue
+		}
+		_, inData := t.userInputs.Data[keyPrefix+step.Key]
+		_, inLists := t.userInputs.Lists[keyPrefix+step.Key]
+		if inData || inLists {
+			lines = append(lines, w.inputLine(t.userInputs, step, keyPrefix+step.Key, lang))
+		}
+	}
+	return lines
+}
+
+// summaryButtons returns the confirm button and the edit button of each answered step, translated.
+func (w *TBotWorkflowController) summaryButtons(t *workflowTracker, lang string) (string, []string, []*TBotWorkflowStep) {
+	summary := t.CurrentStep.Summary
+	steps := w.answeredSteps(t)
+	edits := make([]string, 0, len(steps))
+	for _, step := range steps {
+		edits = append(edits, fmt.Sprintf(w.translate(lang, summary.editButtonText), w.translate(lang, step.Name)))
+	}
+	return w.translate(lang, summary.confirmButtonText), edits, steps
+}
+
+// summaryKB returns a keyboard with an edit button per answered step, the confirm button and the back and cancel buttons.
+func (w *TBotWorkflowController) summaryKB(t *workflowTracker, lang string) *tgbotapi.ReplyKeyboardMarkup {
+	confirm, edits, _ := w.summaryButtons(t, lang)
+	rows := [][]tgbotapi.KeyboardButton{}
+	for _, edit := range edits {
+		rows = append(rows, tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(edit)))
+	}
+	last := tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButton(confirm))
+	if back := w.getBackBtnConfig(t); back.backButtonExists {
+		last = append(last, tgbotapi.NewKeyboardButton(w.translate(lang, back.backButtonText)))
+	}
+	if cancel := w.getCancelBtnConfig(t); cancel.cancelButtonExists {
+		last = append(last, tgbotapi.NewKeyboardButton(w.translate(lang, cancel.cancelButtonText)))
+	}
+	rows = append(rows, last)
+	kb := tgbotapi.NewReplyKeyboard(rows...)
+	kb.Selective = true
+	return &kb
+}
+
+// summaryInput handles the message of the user at a summary step.
+// Returns true if the user confirmed, or the step to edit. If neither, the string returned is sent to the user.
+func (w *TBotWorkflowController) summaryInput(msg *tgbotapi.Message, t *workflowTracker, lang string) (bool, *TBotWorkflowStep, string) {
+	confirm, edits, steps := w.summaryButtons(t, lang)
+	if msg.Text == confirm {
+		return true, nil, ""
+	}
+	for i, edit := range edits {
+		if msg.Text == edit {
+			return false, steps[i], ""
+		}
+	}
+	return false, nil, fmt.Sprintf(w.translate(lang, defaultInvalidInputReplyText), msg.Text)
+}
+
+// edit moves the user from the summary step to the step to edit.
+// Once the step is answered, the user returns to the summary step.
+// Repeatable steps keep their inputs: the back button removes the last one and the done button returns to the summary.
+// SubWorkflow steps run the sub-workflow again from its RootStep and return to the summary step once it is completed.
+func (w *TBotWorkflowController) edit(ctx context.Context, t *workflowTracker, step *TBotWorkflowStep, msg *tgbotapi.Message) error {
+	w.Logger.Printf("Current Step: %s, Editing Step: %s", t.CurrentStep.Name, step.Name)
+	w.onStepExit(t, msg)
+	w.recordTransition(t, msg, t.CurrentStep, step, TransitionEdit, "")
+	t.summaryStepID = t.CurrentStep.id()
+	t.CurrentStep = step
+	if step.SubWorkflow != "" {
+		w.deleteSubInputs(t, step)
+		return w.enter(ctx, t, msg)
+	}
+	w.onStepEnter(t, msg)
+	return nil
+}
+
+// backToSummary moves the user back to the summary step the CurrentStep is edited from.
+// Returns false if the user is not editing a step.
+func (w *TBotWorkflowController) backToSummary(t *workflowTracker, msg *tgbotapi.Message) bool {
+	if t.summaryStepID == "" {
+		return false
+	}
+	summary := t.current().FindStep(t.summaryStepID)
+	t.summaryStepID = ""
+	if summary == nil {
+		return false
+	}
+	w.onStepExit(t, msg)
+	w.recordTransition(t, msg, t.CurrentStep, summary, TransitionSummary, "")
+	t.CurrentStep = summary
+	w.onStepEnter(t, msg)
+	return true
+}
+
+// endEdit moves the user back to the summary step once the edited CurrentStep is answered.
+// Edited steps with a ConditionFunc continue through the workflow instead, as the following steps may change.
+// The steps visited after them are dropped from the history together with their inputs.
+// Returns false if the user is not editing a step or continues through the workflow.
+func (w *TBotWorkflowController) endEdit(t *workflowTracker, msg *tgbotapi.Message) bool {
+	if t.summaryStepID == "" || !t.CurrentStep.hasCondition() {
+		return w.backToSummary(t, msg)
+	}
+	t.summaryStepID = ""
+	for i, id := range t.history {
+		if id != t.CurrentStep.id() {
+			continue
+		}
+		for _, droppedID := range t.history[i+1:] {
+			if dropped := t.current().FindStep(droppedID); dropped != nil {
+				w.deleteStepInputs(t, dropped)
+				t.deleteItems(t.dataKey(dropped.Key))
+			}
+		}
+		t.history = t.history[:i]
+		break
+	}
+	return false
+}
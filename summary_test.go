@@ -0,0 +1,176 @@
+package tbotworkflow
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// newSignupWorkflow returns a workflow capturing a Name, an Email and a Plan, asking the Seats of the Pro plan,
+// and showing a summary before completion.
+func newSignupWorkflow(cmd string) TBotWorkflow {
+	name := NewWorkflowStep("Name", "Name", "Please enter your name", nil)
+	email := NewWorkflowStep("Email", "Email", "Please enter your email", nil)
+	planKB := tgbotapi.NewReplyKeyboard(tgbotapi.NewKeyboardButtonRow(
+		tgbotapi.NewKeyboardButton("Basic"), tgbotapi.NewKeyboardButton("Pro")))
+	plan := NewWorkflowStep("Plan", "Plan", "Please select a plan", &planKB)
+	seats := NewWorkflowStep("Seats", "Seats", "How many seats?", nil)
+	review := NewWorkflowStep("Review", "", "Please review", nil)
+	review.Summary = NewSummaryConfig("Confirm", "Edit %s")
+	done := NewWorkflowStep("Done", "", "Signed up", nil)
+	name.Next = &email
+	email.Next = &plan
+	plan.ConditionFunc = func(msg *tgbotapi.Message) string { return msg.Text }
+	plan.ConditionalNext["Basic"] = &review
+	plan.ConditionalNext["Pro"] = &seats
+	seats.Next = &review
+	review.Next = &done
+
+	wf := NewWorkflow("SignupWF", cmd, &name)
+	wf.CancelButtonConfig = NewCancelButtonConfig("RESET", "Clearing input. Please start again")
+	wf.BackButtonConfig = NewBackButtonConfig("BACK")
+	return wf
+}
+
+func TestSummary(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	signupWF := newSignupWorkflow("SIGNUP")
+	mustAddWorkflow(t, wfc, &signupWF)
+
+	runMessages(wfc, 1, "/SIGNUP", "Alice", "a@example.com", "Basic")
+	summary := sentChattables[len(sentChattables)-1].(tgbotapi.MessageConfig)
+	expected := "Please review\n\nName: Alice\nEmail: a@example.com\nPlan: Basic"
+	if summary.Text != expected {
+		t.Errorf("Expected summary %q but got %q", expected, summary.Text)
+	}
+	kb, ok := summary.ReplyMarkup.(*tgbotapi.ReplyKeyboardMarkup)
+	if !ok || len(kb.Keyboard) != 4 || kb.Keyboard[1][0].Text != "Edit Email" ||
+		len(kb.Keyboard[3]) != 3 || kb.Keyboard[3][0].Text != "Confirm" || kb.Keyboard[3][2].Text != "RESET" {
+		t.Errorf("Expected edit, confirm, back and cancel buttons but got %+v", summary.ReplyMarkup)
+	}
+
+	// Edit returns to the summary once the step is answered.
+	runMessages(wfc, 1, "Edit Email")
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Please enter your email" {
+		t.Errorf("Expected the edited step but got %s", reply)
+	}
+	runMessages(wfc, 1, "b@example.com")
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Please review\n\nName: Alice\nEmail: b@example.com\nPlan: Basic" {
+		t.Errorf("Expected the summary with the edited input but got %q", reply)
+	}
+
+	// Back while editing discards the edit.
+	runMessages(wfc, 1, "Edit Name", "BACK")
+	msg := mockBotMessage(1, "")
+	if session := wfc.currentSession(&msg); session == nil || session.StepID != "Review" || session.SummaryStepID != "" {
+		t.Errorf("Expected to return to the summary but got %+v", session)
+	}
+
+	userInput, result, err := runMessages(wfc, 1, "Confirm")
+	if result != ResultCompleted || err != nil {
+		t.Fatalf("Expected completed workflow but got %v/%v", result, err)
+	}
+	if userInput.Data["Email"] != "b@example.com" || userInput.Data["Name"] != "Alice" {
+		t.Errorf("Expected the edited inputs but got %v", userInput.Data)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestSummaryInvalidInput(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	signupWF := newSignupWorkflow("SIGNUP")
+	mustAddWorkflow(t, wfc, &signupWF)
+
+	_, result, _ := runMessages(wfc, 1, "/SIGNUP", "Alice", "a@example.com", "Basic", "Edit Seats")
+	if result != ResultValidationFailed {
+		t.Errorf("Expected validation failure for a step not answered but got %v", result)
+	}
+	if reply := sentMsgs[len(sentMsgs)-2].Text; reply != "Invalid input Edit Seats. Please try again" {
+		t.Errorf("Expected invalid input reply but got %s", reply)
+	}
+
+	// Back at the summary goes back to the previous step.
+	runMessages(wfc, 1, "BACK")
+	msg := mockBotMessage(1, "")
+	if session := wfc.currentSession(&msg); session == nil || session.StepID != "Plan" {
+		t.Errorf("Expected to go back to Plan but got %+v", session)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestSummaryEditCondition(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	signupWF := newSignupWorkflow("SIGNUP")
+	mustAddWorkflow(t, wfc, &signupWF)
+
+	// Editing a step with a ConditionFunc continues through the new branch.
+	runMessages(wfc, 1, "/SIGNUP", "Alice", "a@example.com", "Basic", "Edit Plan", "Pro")
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "How many seats?" {
+		t.Errorf("Expected the step of the new branch but got %s", reply)
+	}
+	runMessages(wfc, 1, "5")
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Please review\n\nName: Alice\nEmail: a@example.com\nPlan: Pro\nSeats: 5" {
+		t.Errorf("Expected the summary with the new branch but got %q", reply)
+	}
+	msg := mockBotMessage(1, "")
+	if session := wfc.currentSession(&msg); session == nil || len(session.History) != 4 {
+		t.Errorf("Expected the history of the new branch but got %+v", session)
+	}
+
+	// The inputs of the branch left by the edit are removed.
+	runMessages(wfc, 1, "Edit Plan", "Basic")
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Please review\n\nName: Alice\nEmail: a@example.com\nPlan: Basic" {
+		t.Errorf("Expected the summary without the Seats but got %q", reply)
+	}
+	userInput, result, _ := runMessages(wfc, 1, "Confirm")
+	if result != ResultCompleted {
+		t.Fatalf("Expected completed workflow but got %v", result)
+	}
+	if _, found := userInput.Data["Seats"]; found || userInput.Data["Plan"] != "Basic" {
+		t.Errorf("Expected the inputs of the Basic branch only but got %v", userInput.Data)
+	}
+	if _, found := userInput.Inputs["Seats"]; found {
+		t.Errorf("Expected the structured input of Seats removed but got %v", userInput.Inputs)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestSummarySubWorkflow(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	addressWF := newAddressWorkflow("ADDRESS")
+	orderWF := newOrderWorkflow("ORDER", "Shipping")
+	notes := orderWF.RootStep.Next.Next
+	review := NewWorkflowStep("Review", "", "Please review", nil)
+	review.Summary = NewSummaryConfig("Confirm", "Edit %s")
+	review.Next = notes.Next
+	notes.Next = &review
+	mustAddWorkflow(t, wfc, &addressWF)
+	mustAddWorkflow(t, wfc, &orderWF)
+
+	// The inputs of the sub-workflow are listed with the names of its steps.
+	runMessages(wfc, 1, "/ORDER", "Product1", "Main St", "Berlin", "Fragile")
+	expected := "Please review\n\nProduct: Product1\nStreet: Main St\nCity: Berlin\nNotes: Fragile"
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != expected {
+		t.Errorf("Expected the summary with the address but got %q", reply)
+	}
+
+	// Editing the sub-workflow step runs the sub-workflow again and returns to the summary.
+	runMessages(wfc, 1, "Edit ShipTo")
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != "Please enter the street" {
+		t.Errorf("Expected the first step of the sub-workflow but got %s", reply)
+	}
+	runMessages(wfc, 1, "Elm St", "Hamburg")
+	expected = "Please review\n\nProduct: Product1\nStreet: Elm St\nCity: Hamburg\nNotes: Fragile"
+	if reply := sentMsgs[len(sentMsgs)-1].Text; reply != expected {
+		t.Errorf("Expected the summary with the edited address but got %q", reply)
+	}
+	userInput, result, _ := runMessages(wfc, 1, "Confirm")
+	if result != ResultCompleted || userInput.Data["Shipping.City"] != "Hamburg" || userInput.Data["Notes"] != "Fragile" {
+		t.Errorf("Expected the edited address in the completed inputs but got %v/%v", result, userInput)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
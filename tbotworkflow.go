@@ -1,6 +1,8 @@
 package tbotworkflow
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -8,6 +10,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
@@ -15,6 +18,10 @@ import (
 const (
 	// Default Text sent to the user for an incorrect input.
 	defaultWFNotFoundReplyText string = "Message \"%s\" cannot be processed. Please select valid command."
+	// Default Text sent to the user for an input not matching the keyboard.
+	defaultInvalidInputReplyText string = "Invalid input %s. Please try again"
+	// Default Text sent to the user when the next step cannot be determined.
+	defaultBrokenWorkflowReplyText string = "Workflow %s broken. Cannot determine next step for CurrentStep: %s"
 
 	parseModeHTML     string = "HTML"
 	parseModeMarkDown string = "MarkdownV2"
@@ -40,11 +47,33 @@ func NewCancelButtonConfig(buttonText string, buttonReply string) *CancelButtonC
 	}
 }
 
+// BackButtonConfig will tell the workflow if a particular user input
+// should be considered as a request to go back to the previous step.
+type BackButtonConfig struct {
+	backButtonExists bool
+	backButtonText   string
+}
+
+// NewBackButtonConfig returns a pointer to back button config.
+// buttonText: Tells the workflow step what is the back buttons text. E.g. "Back", "Previous" etc.
+// When user presses the Back button, the workflow returns to the previous step and
+// the input captured at that step is removed from the UserInputs.
+func NewBackButtonConfig(buttonText string) *BackButtonConfig {
+	return &BackButtonConfig{
+		backButtonExists: true,
+		backButtonText:   buttonText,
+	}
+}
+
 // TBotWorkflowStep is the baisc unit of the workflow.
 // A workflow consists of a number of TBotWorkflowStep's chained together.
 type TBotWorkflowStep struct {
 	// Name of the workflow step.
 	Name string
+	// Stable identifier of the step used to persist the workflow progress. Must be unique within a workflow.
+	// Defaults to Name when empty, followed by "#2", "#3" etc. for the steps sharing their Name in breadth first order.
+	// Set the ID of steps sharing their Name so that the sessions remain valid when the workflow changes.
+	ID string
 	// Text that should be sent to the user at start of the step.
 	ReplyText string
 	// Key for the user input that will be available at the end of the workflow.
@@ -52,22 +81,84 @@ type TBotWorkflowStep struct {
 	// Keyboard that should be presented to the user.
 	// Set to nil to display the default text input keyboard.
 	KB *tgbotapi.ReplyKeyboardMarkup
+	// Inline keyboard that should be presented to the user. Takes priority over KB.
+	// The CallbackData of the pressed button is the user input for the step.
+	// Use ExecuteUpdate to process the resulting CallbackQuery updates.
+	InlineKB *tgbotapi.InlineKeyboardMarkup
+	// Remove the inline keyboard from the message once the user has pressed one of its buttons.
+	EditCallbackMessage bool
 	// Next step to be executed after this step.
 	// Set to nil for the last step.
 	Next *TBotWorkflowStep
 	// Function to be evaluated to determine the next step for conditional workflows.
 	// If ConditionFunc is set, Step defined in "Next" will be ignored.
 	ConditionFunc func(msg *tgbotapi.Message) string
+	// Context aware variant of ConditionFunc. Takes priority over ConditionFunc.
+	// If it returns an error, the message is not processed any further and ResultAborted is returned.
+	ConditionContextFunc func(ctx context.Context, msg *tgbotapi.Message) (string, error)
 	// A map of "ConditionFunc" outputs and the TBotWorkflowStep that should be executed for each of those outputs.
 	ConditionalNext map[string]*TBotWorkflowStep
+	// Command of a registered workflow to run as this step, e.g. to reuse the same steps in several workflows.
+	// The user goes through the steps of the sub-workflow and continues with the next step of this workflow
+	// when the last step of the sub-workflow is reached. The last step of the sub-workflow is only sent
+	// if this is the last step of the workflow. ReplyText, KB and the validation of this step are not used.
+	// The inputs of the sub-workflow are stored with the Key of this step and a dot as prefix, e.g. "Address.City".
+	// Leave Key empty to store them with the inputs of this workflow.
+	SubWorkflow string
+	// Repeat config to make this step collect a list of inputs, e.g. the items of a shopping cart.
+	// The step is repeated until the done button is pressed, the maximum number of inputs is given
+	// or RepeatUntilFunc returns true. The inputs are stored in order in Lists and ListInputs of the UserInputs.
+	// The back button removes the last input, or goes back to the previous step if there is none.
+	// Inputs given once the maximum is reached, e.g. after going back to the step, are not added.
+	Repeat *RepeatConfig
+	// Function evaluated after each input of a repeatable step. The workflow moves on when it returns true.
+	RepeatUntilFunc func(ui *UserInputs) bool
+	// Summary config to make this step show the inputs given so far, labelled with the step names.
+	// The user can confirm to move on to the next step, or edit a single input and return to this step.
+	// Steps with a ConditionFunc continue through the workflow once edited, since the following steps may change.
+	// The inputs of SubWorkflow steps are listed with the names of the sub-workflow steps and edited by running it again.
+	// KB and the validation of this step are not used. ReplyText is sent above the inputs.
+	Summary *SummaryConfig
 	// Function to generate the Text that should be sent to the user at start of the step.
 	// If ReplyTextFunc is set, value defined in "ReplyText" is ignored.
 	ReplyTextFunc func(ui *UserInputs) string
+	// Context aware variant of ReplyTextFunc. Takes priority over ReplyTextFunc.
+	// If it returns an error, the message is not processed any further and ResultAborted is returned.
+	// It runs after the hooks of the steps left and entered, which run again if the message is processed again.
+	ReplyTextContextFunc func(ctx context.Context, ui *UserInputs) (string, error)
 	// Function to validate the users input.
 	// If the validation fails (function returns false), the string returned by this function is sent to the user.
 	ValidateInputFunc func(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool)
+	// Context aware variant of ValidateInputFunc. Takes priority over ValidateInputFunc.
+	// If it returns an error, the message is not processed any further and ResultAborted is returned.
+	ValidateInputContextFunc func(ctx context.Context, msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool, error)
+	// Kind of message accepted as the user input, e.g. InputPhoto or InputLocation. Defaults to InputText.
+	// Inputs of other kinds are not validated against the KB, so that the KB can offer
+	// RequestLocation and RequestContact buttons.
+	InputKind InputKind
+	// Text sent to the user when the message is not of the InputKind of the step.
+	WrongInputKindReplyText string
+	// Type of value the user input is parsed into after validation, e.g. ValueInt or ValueTime.
+	// The parsed value is available through the typed accessors of the UserInputs.
+	ValueType ValueType
+	// Layout used to parse ValueTime inputs. Defaults to "2006-01-02".
+	TimeLayout string
+	// Values accepted by ValueEnum steps. Defaults to the buttons of the keyboard.
+	EnumValues []string
+	// Text sent to the user when the input cannot be parsed into the ValueType.
+	InvalidValueReplyText string
 	// Cancel button config for the step. Overrides the config set in the TBotWorkflow.
 	CancelButtonConfig *CancelButtonConfig
+	// Back button config for the step. Overrides the config set in the TBotWorkflow.
+	BackButtonConfig *BackButtonConfig
+	// Inactivity timeout while the user is at this step. Overrides the IdleTimeout set in the TBotWorkflow.
+	IdleTimeout time.Duration
+	// Function called when the user enters this step, before the OnStepEnter hooks of the workflow and controller.
+	OnEnter HookFunc
+	// Function called when the user leaves this step, before the OnStepExit hooks of the workflow and controller.
+	OnExit HookFunc
+
+	defaultID string
 }
 
 // NewWorkflowStep returns a pointer to TBotWorkflowStep for given
@@ -91,54 +182,116 @@ func NewWorkflowStep(name string, key string, replyText string,
 }
 
 func (s *TBotWorkflowStep) isLastStep() bool {
-	return s.Next == nil && s.ConditionFunc == nil
+	return s.Next == nil && !s.hasCondition()
+}
+
+func (s *TBotWorkflowStep) id() string {
+	if s.ID != "" {
+		return s.ID
+	}
+	if s.defaultID != "" {
+		return s.defaultID
+	}
+	return s.Name
+}
+
+// assignStepIDs gives the steps without ID a default ID which is unique within the workflow:
+// their Name, followed by "#2", "#3" etc. for the steps sharing their Name in breadth first order.
+func (wf *TBotWorkflow) assignStepIDs() {
+	steps := wf.steps()
+	taken := make(map[string]bool)
+	for _, step := range steps {
+		if step.ID != "" {
+			taken[step.ID] = true
+		}
+	}
+	for _, step := range steps {
+		if step.ID != "" {
+			continue
+		}
+		id := step.Name
+		for n := 2; taken[id]; n++ {
+			id = fmt.Sprintf("%s#%d", step.Name, n)
+		}
+		taken[id] = true
+		step.defaultID = id
+	}
 }
 
 // UserInputs captures the user inputs for each step of the workflow
 type UserInputs struct {
-	// Telegram User ID
+	// Telegram User ID of the user who started the workflow
 	UID int64
+	// Telegram Chat ID the workflow ran in
+	ChatID int64
+	// Telegram Chat type the workflow ran in. "private", "group", "supergroup" or "channel"
+	ChatType string
 	// Telegram Command
 	Command string
+	// Language the texts were sent to the user in.
+	Language string
 	// Data map to store the user inputs.
 	// Map key is the "Key" defined in the TBotWorkflowStep
 	// Map value is the Text entered by the user.
+	// For other input kinds the value is the text representation of the InputValue.
 	Data map[string]string
+	// Inputs map to store the structured user inputs, e.g. file IDs and coordinates.
+	// Map key is the "Key" defined in the TBotWorkflowStep
+	Inputs map[string]InputValue
+	// Lists map to store the user inputs of the repeatable steps, in the order they were given.
+	// Map key is the "Key" defined in the TBotWorkflowStep
+	Lists map[string][]string
+	// ListInputs map to store the structured user inputs of the repeatable steps, in the order they were given.
+	// Map key is the "Key" defined in the TBotWorkflowStep
+	ListInputs map[string][]InputValue
 }
 
 // workflowTracker tracks at which step each user is in a given Workflow
 type workflowTracker struct {
-	UID                int64
+	key                SessionKey
+	chatID             int64
 	WorkflowName       string
 	Command            string
 	CurrentStep        *TBotWorkflowStep
 	userInputs         UserInputs
 	cancelButtonConfig *CancelButtonConfig
+	backButtonConfig   *BackButtonConfig
+	idleTimeout        time.Duration
+	expiresAt          time.Time
+	// IDs of the steps visited before the CurrentStep, oldest first.
+	history []string
+	wf      *TBotWorkflow
+	// Sub-workflows being run, outermost first.
+	frames []workflowFrame
+	// ID of the summary step the CurrentStep is edited from.
+	summaryStepID string
+	// Time the user entered the CurrentStep.
+	enteredAt time.Time
 }
 
-// userWfTracker keeps track of the workflow progress for all the users.
-type userWfTracker struct {
-	tracker map[int64]*workflowTracker
-	m       sync.Mutex
-}
-
-func (u *userWfTracker) Add(uid int64, wfTracker *workflowTracker) {
-	u.m.Lock()
-	defer u.m.Unlock()
-	u.tracker[uid] = wfTracker
-}
-
-func (u *userWfTracker) Delete(uid int64) {
-	u.m.Lock()
-	defer u.m.Unlock()
-	delete(u.tracker, uid)
-}
+func (t *workflowTracker) toSession(now time.Time) *Session {
+	session := &Session{
+		Key:           t.key,
+		ChatID:        t.chatID,
+		WorkflowName:  t.WorkflowName,
+		Command:       t.Command,
+		StepID:        t.CurrentStep.id(),
+		UserInputs:    t.userInputs,
+		History:       t.history,
+		Frames:        t.sessionFrames(),
+		SummaryStepID: t.summaryStepID,
+		StepEnteredAt: t.enteredAt,
+		LastActive:    now,
+	}
 
-func (u *userWfTracker) Get(uid int64) (*workflowTracker, bool) {
-	u.m.Lock()
-	defer u.m.Unlock()
-	wft, ok := u.tracker[uid]
-	return wft, ok
+	timeout := t.idleTimeout
+	if t.CurrentStep.IdleTimeout > 0 {
+		timeout = t.CurrentStep.IdleTimeout
+	}
+	if timeout > 0 {
+		session.ExpiresAt = now.Add(timeout)
+	}
+	return session
 }
 
 // TBotWorkflow is the workflow to be triggered for a particular Bot Command
@@ -151,6 +304,20 @@ type TBotWorkflow struct {
 	RootStep *TBotWorkflowStep
 	// Cancel button config for this workflow. Can be overridden by the config set in the TBotWorkflowStep.
 	CancelButtonConfig *CancelButtonConfig
+	// Back button config for this workflow. Can be overridden by the config set in the TBotWorkflowStep.
+	BackButtonConfig *BackButtonConfig
+	// Inactivity timeout after which the user's progress is discarded.
+	// Zero means the progress never expires. Can be overridden by the IdleTimeout set in the TBotWorkflowStep.
+	IdleTimeout time.Duration
+	// Text sent to the user when the progress is discarded due to inactivity.
+	// Set to empty string to expire silently.
+	ExpiredReplyText string
+	// Set to true to share the progress of the workflow between all the members of a chat.
+	// Any member of a group can then advance the workflow started by another member.
+	// By default each user has their own progress in each chat.
+	ChatScoped bool
+	// Functions called on the lifecycle events of this workflow.
+	Hooks WorkflowHooks
 }
 
 // NewWorkflow returns a TBotWorkflow
@@ -164,14 +331,37 @@ func NewWorkflow(name string, command string, rootStep *TBotWorkflowStep) TBotWo
 	return wf
 }
 
+// FindStep returns the step with the given ID reachable from the RootStep.
+// Returns nil if no such step exists.
+func (wf *TBotWorkflow) FindStep(id string) *TBotWorkflowStep {
+	visited := make(map[*TBotWorkflowStep]bool)
+	queue := []*TBotWorkflowStep{wf.RootStep}
+	for len(queue) > 0 {
+		step := queue[0]
+		queue = queue[1:]
+		if step == nil || visited[step] {
+			continue
+		}
+		visited[step] = true
+		if step.id() == id {
+			return step
+		}
+		queue = append(queue, step.Next)
+		for _, next := range step.ConditionalNext {
+			queue = append(queue, next)
+		}
+	}
+	return nil
+}
+
 // TBotWorkflowController controls all the workflows and their execution
 type TBotWorkflowController struct {
 	// Name of the Workflow Controller.
 	Name string
 	// A map of all the workflows controlled.
 	workflows map[string]*TBotWorkflow
-	// For tracking the workflow progress of all the users.
-	userWFTracker userWfTracker
+	// For persisting the workflow progress of all the users.
+	sessionStore SessionStore
 	// For logging useful information.
 	// Logger is disabled by default but can be overriden/enabled/disabled
 	// using the methods provided on the WorkflowController.
@@ -179,12 +369,45 @@ type TBotWorkflowController struct {
 	// Function to override the default Text sent to the users in case
 	// this controller cannot handle the command sent by the user.
 	WorkflowNotFoundReplyTextFunc func(msg *tgbotapi.Message) string
+	// Context aware variant of WorkflowNotFoundReplyTextFunc. Takes priority over WorkflowNotFoundReplyTextFunc.
+	WorkflowNotFoundReplyTextContextFunc func(ctx context.Context, msg *tgbotapi.Message) (string, error)
 	// Global function to validate the user inputs.
 	// ValidateInputFunc on TBotWorkflowStep takes priority over this function.
 	ValidateInputFunc func(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool)
+	// Context aware variant of ValidateInputFunc. Takes priority over ValidateInputFunc.
+	ValidateInputContextFunc func(ctx context.Context, msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool, error)
+	// Function used to answer the CallbackQuery of inline keyboard buttons, e.g. BotAPI.Request.
+	// If not set, the callback is answered using the send function and its error is ignored
+	// since Telegram does not return a Message for answered callbacks.
+	RequestFunc func(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	// Function called with the user inputs captured so far
+	// whenever a session is discarded due to inactivity.
+	OnExpire func(ui *UserInputs)
+	// Functions called on the lifecycle events of all the workflows,
+	// after the hooks set on the TBotWorkflow.
+	Hooks WorkflowHooks
+	// Catalog used to translate the texts sent to the users, including the keyboard buttons.
+	// Texts are sent as defined in the workflows if not set.
+	Catalog MessageCatalog
+	// Language used for the users whose Telegram client does not send a language.
+	DefaultLanguage string
+	// Metrics of the workflows, e.g. a PrometheusMetrics. Nothing is measured if not set.
+	Metrics Metrics
+	// Recorder of the messages, transitions, validations, replies and results of the conversations.
+	// Nothing is recorded if not set.
+	Recorder TranscriptRecorder
+	// Languages set with SetUserLanguage.
+	languages   map[int64]string
+	languagesMu sync.Mutex
+	// Middleware added with Use.
+	middleware []Middleware
+	// Serializes the messages of each chat.
+	chatLocks chatLocks
 	// Telegram text parse mode. HTML or MarkdownV2.
 	// Default value is HTML
 	parseMode string
+	// Clock used for the idle timeouts.
+	now func() time.Time
 }
 
 // NewWorkflowController returns a pointer to a new WorkflowController
@@ -192,11 +415,12 @@ func NewWorkflowController(name string) *TBotWorkflowController {
 	logger := log.New(os.Stdout, "", log.Lshortfile|log.LstdFlags)
 	logger.SetOutput(ioutil.Discard)
 	wfs := TBotWorkflowController{
-		Name:          name,
-		workflows:     make(map[string]*TBotWorkflow),
-		userWFTracker: userWfTracker{tracker: make(map[int64]*workflowTracker)},
-		Logger:        logger,
-		parseMode:     parseModeHTML,
+		Name:         name,
+		workflows:    make(map[string]*TBotWorkflow),
+		sessionStore: NewMemorySessionStore(),
+		Logger:       logger,
+		parseMode:    parseModeHTML,
+		now:          time.Now,
 	}
 
 	return &wfs
@@ -223,21 +447,85 @@ func (w *TBotWorkflowController) SetMsgParseMode(parseMode string) {
 	w.parseMode = parseMode
 }
 
+// SetSessionStore can be used to override the default in-memory session store,
+// e.g. with a FileSessionStore so that the workflow progress survives restarts.
+func (w *TBotWorkflowController) SetSessionStore(store SessionStore) {
+	w.sessionStore = store
+}
+
 // AddWorkflow is used to add a single workflow to the controller.
-func (w *TBotWorkflowController) AddWorkflow(wf *TBotWorkflow) {
+// Returns an error if the workflow fails Validate or
+// another workflow is already registered for the same Command.
+func (w *TBotWorkflowController) AddWorkflow(wf *TBotWorkflow) error {
+	if wf.RootStep != nil {
+		wf.assignStepIDs()
+	}
+	if err := wf.Validate(); err != nil {
+		return err
+	}
+	if other, found := w.workflows[wf.Command]; found && other != wf {
+		return fmt.Errorf("workflow %s is already registered for Command: %s", other.Name, wf.Command)
+	}
 	w.workflows[wf.Command] = wf
+	return nil
 }
 
 // Execute runs one of the registered workflows given a Message from the user.
 // At the end of the workflow, this method returns the user inputs captured at each step.
 // bool = true means workflow has ended. UserInputs pointer will be "nil" till the workflow ends.
 // This method takes the Message from the user and the Send function of the Telegram Bot API as inputs.
+// Errors are written to the Logger. Use ExecuteE to handle them.
+// A nil Message or a Message without a sender is ignored.
+// Execute is safe for concurrent use. Messages of the same chat are processed one at a time.
 func (w *TBotWorkflowController) Execute(msg *tgbotapi.Message,
 	sendFunc func(c tgbotapi.Chattable) (tgbotapi.Message, error)) (*UserInputs, bool) {
-	if w.userWFTracker.tracker == nil {
-		w.userWFTracker.tracker = make(map[int64]*workflowTracker)
+	userInputs, result, _ := w.ExecuteE(msg, sendFunc)
+	return userInputs, result == ResultCompleted
+}
+
+// ExecuteE runs one of the registered workflows given a Message from the user.
+// Returns what happened to the workflow of the user and the user inputs once the workflow is ResultCompleted.
+// The error is ErrWorkflowNotFound or ErrBrokenWorkflow for the matching results,
+// a *SendError if a message could not be sent or the error of the SessionStore.
+// The user inputs are returned even if the last message of the workflow could not be sent.
+func (w *TBotWorkflowController) ExecuteE(msg *tgbotapi.Message,
+	sendFunc func(c tgbotapi.Chattable) (tgbotapi.Message, error)) (*UserInputs, Result, error) {
+	return w.ExecuteContext(context.Background(), msg, sendFunc)
+}
+
+// ExecuteUpdate runs one of the registered workflows given an Update from the user.
+// Same as Execute, but also processes the CallbackQuery of inline keyboard buttons.
+// Updates without a Message or CallbackQuery are ignored.
+func (w *TBotWorkflowController) ExecuteUpdate(update tgbotapi.Update,
+	sendFunc func(c tgbotapi.Chattable) (tgbotapi.Message, error)) (*UserInputs, bool) {
+	userInputs, result, _ := w.ExecuteUpdateE(update, sendFunc)
+	return userInputs, result == ResultCompleted
+}
+
+// ExecuteUpdateE runs one of the registered workflows given an Update from the user.
+// Same as ExecuteE, but also processes the CallbackQuery of inline keyboard buttons.
+// Updates without a Message or CallbackQuery are ignored and return ResultNotFound without an error.
+func (w *TBotWorkflowController) ExecuteUpdateE(update tgbotapi.Update,
+	sendFunc func(c tgbotapi.Chattable) (tgbotapi.Message, error)) (*UserInputs, Result, error) {
+	return w.ExecuteUpdateContext(context.Background(), update, sendFunc)
+}
+
+func (w *TBotWorkflowController) execute(ctx context.Context, msg *tgbotapi.Message, callback *tgbotapi.CallbackQuery,
+	sendFunc func(c tgbotapi.Chattable) (tgbotapi.Message, error)) (*UserInputs, Result, error) {
+	if w.sessionStore == nil {
+		w.sessionStore = NewMemorySessionStore()
+	}
+	if w.now == nil {
+		w.now = time.Now
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, ResultAborted, err
+	}
+	if callback != nil {
+		w.answerCallback(callback, sendFunc)
 	}
 
+	s := &sender{sendFunc: sendFunc, logger: w.Logger}
 	reply := tgbotapi.NewMessage(msg.Chat.ID, "")
 	reply.ReplyToMessageID = msg.MessageID
 	reply.ParseMode = w.parseMode
@@ -246,126 +534,303 @@ func (w *TBotWorkflowController) Execute(msg *tgbotapi.Message,
 	userName := msg.From.UserName
 	msgText := msg.Text
 	w.Logger.Printf("Received message: %s from User: %d/%s", msgText, userId, userName)
+	lang := w.language(msg)
 
 	var wf *TBotWorkflow
 	var found bool
+	// Tells if the user's own session is dropped once the chat scoped workflow started by the message is entered.
+	var dropUserSession bool
 
 	if msg.IsCommand() {
 		cmd := strings.ToUpper(msg.Command())
 		if wf, found = w.workflows[cmd]; !found {
-			reply.Text = w.getWFNotFoundReplyText(msg, cmd)
-			if _, err := sendFunc(reply); err != nil {
-				w.Logger.Printf("Failed sending message. Error: %v", err)
+			text, err := w.getWFNotFoundReplyText(ctx, msg, cmd, lang)
+			if err != nil {
+				return nil, ResultAborted, err
 			}
-			return nil, false
+			reply.Text = text
+			s.send(reply)
+			w.incCounter(MetricNotFound, MetricLabels{})
+			s.fail(fmt.Errorf("%w for Command: %s", ErrWorkflowNotFound, cmd))
+			return nil, ResultNotFound, s.err
 		}
 	}
 
-	userWfTracker, found := w.userWFTracker.Get(userId)
+	// The user's own session takes priority over a chat scoped session in the same chat.
+	userKey := SessionKey{ChatID: msg.Chat.ID, UID: userId}
+	chatKey := SessionKey{ChatID: msg.Chat.ID}
+	userWfTracker, found, err := w.getTracker(userKey)
+	if !found && err == nil {
+		userWfTracker, found, err = w.getTracker(chatKey)
+	}
+	if err != nil {
+		s.fail(err)
+	}
+	if found && userWfTracker.expired(w.now()) {
+		w.expire(userWfTracker.toSession(w.now()), s)
+		if !msg.IsCommand() {
+			return nil, ResultExpired, s.err
+		}
+		userWfTracker, found = nil, false
+	}
 	if msg.IsCommand() {
 		w.Logger.Printf("User not found. Adding entry to tracker")
 
 		cmd := strings.ToUpper(msg.Command())
+		sessionKey := userKey
+		if wf.ChatScoped {
+			sessionKey = chatKey
+			dropUserSession = true
+		}
 		wfTracker := workflowTracker{
-			UID:                userId,
-			WorkflowName:       wf.Name,
-			Command:            cmd,
-			CurrentStep:        wf.RootStep,
-			userInputs:         UserInputs{UID: userId, Command: cmd, Data: make(map[string]string)},
+			key:          sessionKey,
+			chatID:       msg.Chat.ID,
+			WorkflowName: wf.Name,
+			Command:      cmd,
+			CurrentStep:  wf.RootStep,
+			userInputs: UserInputs{
+				UID:        userId,
+				ChatID:     msg.Chat.ID,
+				ChatType:   msg.Chat.Type,
+				Command:    cmd,
+				Data:       make(map[string]string),
+				Inputs:     make(map[string]InputValue),
+				Lists:      make(map[string][]string),
+				ListInputs: make(map[string][]InputValue),
+			},
 			cancelButtonConfig: wf.CancelButtonConfig,
+			backButtonConfig:   wf.BackButtonConfig,
+			idleTimeout:        wf.IdleTimeout,
+			wf:                 wf,
 		}
-		w.userWFTracker.Add(userId, &wfTracker)
 		userWfTracker = &wfTracker
 		found = true
+		w.onStart(userWfTracker, msg)
+		if err := w.enter(ctx, userWfTracker, msg); err != nil {
+			return w.broken(userWfTracker, s, reply, err)
+		}
 	}
 
 	if !found {
-		reply.Text = w.getWFNotFoundReplyText(msg, msg.Text)
-		reply.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{RemoveKeyboard: true, Selective: true}
-
-		if _, err := sendFunc(reply); err != nil {
-			w.Logger.Printf("Failed sending message. Error: %v", err)
+		if reply.Text, err = w.getWFNotFoundReplyText(ctx, msg, msg.Text, lang); err != nil {
+			return nil, ResultAborted, err
 		}
-		return nil, false
+		reply.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{RemoveKeyboard: true, Selective: true}
+		s.send(reply)
+		w.incCounter(MetricNotFound, MetricLabels{})
+		s.fail(fmt.Errorf("%w for User: %d in Chat: %d", ErrWorkflowNotFound, userId, msg.Chat.ID))
+		return nil, ResultNotFound, s.err
 	}
 
+	userWfTracker.userInputs.Language = lang
+	msg = w.sourceMessage(msg, userWfTracker, lang)
+	msgText = msg.Text
+
 	cancelBtnConfig := w.getCancelBtnConfig(userWfTracker)
 	if cancelBtnConfig.cancelButtonExists && msgText == cancelBtnConfig.cancelButtonText {
-		w.userWFTracker.Delete(userId)
-		reply.Text = cancelBtnConfig.cancelButtonReply
+		s.fail(w.deleteSession(userWfTracker.key))
+		w.onCancel(userWfTracker, msg)
+		reply.Text = w.translate(lang, cancelBtnConfig.cancelButtonReply)
 		reply.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{RemoveKeyboard: true, Selective: true}
-
-		if _, err := sendFunc(reply); err != nil {
-			w.Logger.Printf("Failed sending message. Error: %v", err)
-		}
-		return nil, false
+		s.send(reply)
+		return nil, ResultCancelled, s.err
 	}
 
-	if !msg.IsCommand() {
-		invalidReplyText, ok := w.validateInput(msg, userWfTracker)
-		if ok {
-			userWfTracker.userInputs.Data[userWfTracker.CurrentStep.Key] = msg.Text
-		} else {
+	result := ResultInProgress
+	backBtnConfig := w.getBackBtnConfig(userWfTracker)
+	if !msg.IsCommand() && backBtnConfig.backButtonExists && msgText == backBtnConfig.backButtonText {
+		if userWfTracker.removeLastItem() {
+			w.Logger.Printf("Step: %s, Removed last input", userWfTracker.CurrentStep.Name)
+		} else if w.backToSummary(userWfTracker, msg) {
+			w.Logger.Printf("Step: %s, Edit discarded", userWfTracker.CurrentStep.Name)
+		} else if err := w.goBack(ctx, userWfTracker, msg); err != nil {
+			return w.broken(userWfTracker, s, reply, err)
+		}
+	} else if !msg.IsCommand() && userWfTracker.CurrentStep.Summary != nil {
+		confirmed, step, invalidReplyText := w.summaryInput(msg, userWfTracker, lang)
+		w.record(msg, TranscriptEvent{Type: TranscriptValidation, Command: userWfTracker.Command,
+			Step: userWfTracker.CurrentStep.id(), Valid: confirmed || step != nil, Text: invalidReplyText})
+		if step != nil {
+			if err := w.edit(ctx, userWfTracker, step, msg); err != nil {
+				return w.broken(userWfTracker, s, reply, err)
+			}
+		} else if !confirmed {
+			result = ResultValidationFailed
+			w.incCounter(MetricValidationFailures, userWfTracker.stepLabels())
 			reply.Text = invalidReplyText
 			reply.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{RemoveKeyboard: true, Selective: true}
-
-			if _, err := sendFunc(reply); err != nil {
-				w.Logger.Printf("Failed sending message. Error: %v", err)
+			s.send(reply)
+		} else if !userWfTracker.CurrentStep.isLastStep() {
+			if err := w.moveOn(ctx, userWfTracker, msg); err != nil {
+				return w.broken(userWfTracker, s, reply, err)
 			}
 		}
-
-		if !userWfTracker.CurrentStep.isLastStep() && ok {
-			if userWfTracker.CurrentStep.ConditionFunc != nil {
-				nextStep := userWfTracker.CurrentStep.ConditionalNext[userWfTracker.CurrentStep.ConditionFunc(msg)]
-				if nextStep == nil {
-					reply.Text = fmt.Sprintf("Workflow %s broken. Cannot determine next step for CurrentStep: %s",
-						userWfTracker.WorkflowName, userWfTracker.CurrentStep.Name)
-					reply.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{RemoveKeyboard: true, Selective: true}
-					if _, err := sendFunc(reply); err != nil {
-						w.Logger.Printf("Failed sending message. Error: %v", err)
-					}
-					w.userWFTracker.Delete(userId)
-					return nil, false
+	} else if !msg.IsCommand() {
+		done := userWfTracker.CurrentStep.isDone(msgText)
+		var invalidReplyText string
+		var ok bool
+		var input InputValue
+		if done {
+			invalidReplyText, ok = w.validateDone(userWfTracker)
+		} else {
+			var err error
+			invalidReplyText, ok, err = w.validateInput(ctx, msg, userWfTracker)
+			if err != nil {
+				return nil, ResultAborted, err
+			}
+			if ok {
+				input, invalidReplyText, ok = w.parseInput(msg, userWfTracker.CurrentStep, lang)
+			}
+		}
+		w.record(msg, TranscriptEvent{Type: TranscriptValidation, Command: userWfTracker.Command,
+			Step: userWfTracker.CurrentStep.id(), Valid: ok, Text: invalidReplyText})
+		if ok && !done {
+			if userWfTracker.CurrentStep.Repeat != nil {
+				// Inputs given to a full list are dropped and the workflow moves on.
+				if !userWfTracker.repeatFull() {
+					userWfTracker.addItem(input)
 				}
-				w.Logger.Printf("Current Step: %s, Next Step: %s", userWfTracker.CurrentStep.Name, nextStep.Name)
-				userWfTracker.CurrentStep = nextStep
 			} else {
-				if userWfTracker.CurrentStep.Next != nil {
-					w.Logger.Printf("Current Step: %s, Next Step: %s", userWfTracker.CurrentStep.Name, userWfTracker.CurrentStep.Next.Name)
-				}
-				userWfTracker.CurrentStep = userWfTracker.CurrentStep.Next
+				key := userWfTracker.dataKey(userWfTracker.CurrentStep.Key)
+				userWfTracker.userInputs.Data[key] = input.String()
+				userWfTracker.userInputs.Inputs[key] = input
+			}
+			if callback != nil && userWfTracker.CurrentStep.EditCallbackMessage {
+				w.removeInlineKB(callback, s)
+			}
+		} else if !ok {
+			result = ResultValidationFailed
+			w.incCounter(MetricValidationFailures, userWfTracker.stepLabels())
+			reply.Text = invalidReplyText
+			reply.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{RemoveKeyboard: true, Selective: true}
+			s.send(reply)
+		}
+
+		// Repeatable steps move on with the done button or once the repetition ended.
+		moveOn := ok && (userWfTracker.CurrentStep.Repeat == nil || done || userWfTracker.repeatEnded())
+		if moveOn && w.endEdit(userWfTracker, msg) {
+			w.Logger.Printf("Step: %s, Edit saved", userWfTracker.CurrentStep.Name)
+		} else if !userWfTracker.CurrentStep.isLastStep() && moveOn {
+			if err := w.moveOn(ctx, userWfTracker, msg); err != nil {
+				return w.broken(userWfTracker, s, reply, err)
 			}
 		}
 	}
 
-	reply.Text = userWfTracker.CurrentStep.ReplyText
-	reply.ReplyMarkup = userWfTracker.CurrentStep.KB
+	reply.Text = w.translate(lang, userWfTracker.CurrentStep.ReplyText)
+	reply.ReplyMarkup = w.translateKB(lang, userWfTracker.CurrentStep.KB)
 	if userWfTracker.CurrentStep.KB == nil {
 		reply.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{RemoveKeyboard: true, Selective: true}
 	}
-	if userWfTracker.CurrentStep.ReplyTextFunc != nil {
-		reply.Text = userWfTracker.CurrentStep.ReplyTextFunc(&userWfTracker.userInputs)
+	if userWfTracker.CurrentStep.InlineKB != nil {
+		reply.ReplyMarkup = w.translateInlineKB(lang, userWfTracker.CurrentStep.InlineKB)
+	}
+	if userWfTracker.CurrentStep.Summary != nil {
+		reply.Text = w.summaryText(userWfTracker, lang)
+		reply.ReplyMarkup = w.summaryKB(userWfTracker, lang)
+	}
+	if text, found, err := userWfTracker.CurrentStep.replyText(ctx, &userWfTracker.userInputs); err != nil {
+		return nil, ResultAborted, err
+	} else if found {
+		reply.Text = text
 	}
 	if userWfTracker.CurrentStep.isLastStep() {
 		reply.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{RemoveKeyboard: true, Selective: true}
 	}
-
-	if _, err := sendFunc(reply); err != nil {
-		w.Logger.Printf("Failed sending message. Error: %v", err)
+	s.send(reply)
+	if dropUserSession {
+		// Drop the user's own session so that their messages reach the chat scoped workflow.
+		s.fail(w.deleteSession(userKey))
 	}
 
 	if userWfTracker.CurrentStep.isLastStep() {
 		w.Logger.Println("WF ended. Return all the collected user inputs...")
-		w.userWFTracker.Delete(userId)
-		return &userWfTracker.userInputs, true
+		s.fail(w.deleteSession(userWfTracker.key))
+		w.onComplete(userWfTracker, msg)
+		return &userWfTracker.userInputs, ResultCompleted, s.err
+	}
+
+	if err := w.sessionStore.Put(userWfTracker.key, userWfTracker.toSession(w.now())); err != nil {
+		w.Logger.Printf("Failed saving session. Error: %v", err)
+		s.fail(err)
+	}
+	return nil, result, s.err
+}
+
+// getTracker loads the session for the key from the session store and
+// rehydrates it against the registered workflows.
+func (w *TBotWorkflowController) getTracker(key SessionKey) (*workflowTracker, bool, error) {
+	session, found, err := w.sessionStore.Get(key)
+	if err != nil {
+		w.Logger.Printf("Failed loading session. Error: %v", err)
+		return nil, false, err
+	}
+	if !found {
+		return nil, false, nil
+	}
+
+	wf, found := w.workflows[session.Command]
+	if !found {
+		w.Logger.Printf("Workflow for Command: %s no longer registered. Dropping session", session.Command)
+		return nil, false, w.deleteSession(key)
+	}
+	frames, found := w.workflowFrames(wf, session.Frames)
+	if !found {
+		w.Logger.Printf("SubWorkflows of Workflow: %s no longer registered. Dropping session", wf.Name)
+		return nil, false, w.deleteSession(key)
+	}
+	current := wf
+	if len(frames) > 0 {
+		current = frames[len(frames)-1].wf
+	}
+	step := current.FindStep(session.StepID)
+	if step == nil {
+		w.Logger.Printf("Step: %s not found in Workflow: %s. Dropping session", session.StepID, current.Name)
+		return nil, false, w.deleteSession(key)
 	}
+	if session.UserInputs.Data == nil {
+		session.UserInputs.Data = make(map[string]string)
+	}
+	if session.UserInputs.Inputs == nil {
+		session.UserInputs.Inputs = make(map[string]InputValue)
+	}
+	if session.UserInputs.Lists == nil {
+		session.UserInputs.Lists = make(map[string][]string)
+	}
+	if session.UserInputs.ListInputs == nil {
+		session.UserInputs.ListInputs = make(map[string][]InputValue)
+	}
+
+	return &workflowTracker{
+		key:                key,
+		chatID:             session.ChatID,
+		WorkflowName:       session.WorkflowName,
+		Command:            session.Command,
+		CurrentStep:        step,
+		userInputs:         session.UserInputs,
+		cancelButtonConfig: wf.CancelButtonConfig,
+		backButtonConfig:   wf.BackButtonConfig,
+		idleTimeout:        wf.IdleTimeout,
+		expiresAt:          session.ExpiresAt,
+		history:            session.History,
+		wf:                 wf,
+		frames:             frames,
+		summaryStepID:      session.SummaryStepID,
+		enteredAt:          session.StepEnteredAt,
+	}, true, nil
+}
 
-	return nil, false
+func (w *TBotWorkflowController) deleteSession(key SessionKey) error {
+	if err := w.sessionStore.Delete(key); err != nil {
+		w.Logger.Printf("Failed deleting session. Error: %v", err)
+		return err
+	}
+	return nil
 }
 
 // defaultValidateInput is the default input validation method.
 // This method will compare the user input with the Keyboard Button Text.
-func (w *TBotWorkflowController) defaultValidateInput(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool) {
+func (w *TBotWorkflowController) defaultValidateInput(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup, lang string) (string, bool) {
 	if kb == nil {
 		return "", true
 	}
@@ -388,26 +853,62 @@ func (w *TBotWorkflowController) defaultValidateInput(msg *tgbotapi.Message, kb
 	}
 
 	if !validated {
-		replyText = fmt.Sprintf("Invalid input %s. Please try again", msg.Text)
+		replyText = fmt.Sprintf(w.translate(lang, defaultInvalidInputReplyText), msg.Text)
+	}
+
+	w.Logger.Printf("User input: %s validated: %v", msg.Text, validated)
+	return replyText, validated
+}
+
+// defaultValidateInlineInput is the default input validation method for inline keyboards.
+// This method will compare the user input with the Callback Data of the Keyboard Buttons.
+func (w *TBotWorkflowController) defaultValidateInlineInput(msg *tgbotapi.Message, kb *tgbotapi.InlineKeyboardMarkup, lang string) (string, bool) {
+	buttons := kb.InlineKeyboard
+	validated := false
+	replyText := ""
+
+	if len(buttons) == 0 {
+		validated = true
+	}
+
+	for i := 0; i < len(buttons); i++ {
+		for j := 0; j < len(buttons[i]); j++ {
+			if buttons[i][j].CallbackData != nil && msg.Text == *buttons[i][j].CallbackData {
+				validated = true
+				break
+			}
+		}
+	}
+
+	if !validated {
+		replyText = fmt.Sprintf(w.translate(lang, defaultInvalidInputReplyText), msg.Text)
 	}
 
 	w.Logger.Printf("User input: %s validated: %v", msg.Text, validated)
 	return replyText, validated
 }
 
-func (w *TBotWorkflowController) getWFNotFoundReplyText(msg *tgbotapi.Message, text string) string {
+func (w *TBotWorkflowController) getWFNotFoundReplyText(ctx context.Context, msg *tgbotapi.Message, text string, lang string) (string, error) {
 	replyText := ""
-	if w.WorkflowNotFoundReplyTextFunc != nil {
+	if w.WorkflowNotFoundReplyTextContextFunc != nil {
+		var err error
+		replyText, err = w.WorkflowNotFoundReplyTextContextFunc(ctx, msg)
+		if err = contextErr(ctx, err); err != nil {
+			return "", fmt.Errorf("WorkflowNotFoundReplyTextContextFunc failed: %w", err)
+		}
+	} else if w.WorkflowNotFoundReplyTextFunc != nil {
 		replyText = w.WorkflowNotFoundReplyTextFunc(msg)
 	} else {
-		replyText = fmt.Sprintf(defaultWFNotFoundReplyText, text)
+		replyText = fmt.Sprintf(w.translate(lang, defaultWFNotFoundReplyText), text)
 	}
-	return replyText
+	return replyText, nil
 }
 
 func (w *TBotWorkflowController) getCancelBtnConfig(userWfTracker *workflowTracker) *CancelButtonConfig {
 	if userWfTracker.CurrentStep.CancelButtonConfig != nil {
 		return userWfTracker.CurrentStep.CancelButtonConfig
+	} else if userWfTracker.current().CancelButtonConfig != nil {
+		return userWfTracker.current().CancelButtonConfig
 	} else if userWfTracker.cancelButtonConfig != nil {
 		return userWfTracker.cancelButtonConfig
 	}
@@ -418,15 +919,119 @@ func (w *TBotWorkflowController) getCancelBtnConfig(userWfTracker *workflowTrack
 	}
 }
 
-func (w *TBotWorkflowController) validateInput(msg *tgbotapi.Message, userWfTracker *workflowTracker) (string, bool) {
-	invalidReplyText := ""
-	ok := true
-	if userWfTracker.CurrentStep.ValidateInputFunc != nil {
-		invalidReplyText, ok = userWfTracker.CurrentStep.ValidateInputFunc(msg, userWfTracker.CurrentStep.KB)
-	} else if w.ValidateInputFunc != nil {
-		invalidReplyText, ok = w.ValidateInputFunc(msg, userWfTracker.CurrentStep.KB)
+func (w *TBotWorkflowController) getBackBtnConfig(userWfTracker *workflowTracker) *BackButtonConfig {
+	if userWfTracker.CurrentStep.BackButtonConfig != nil {
+		return userWfTracker.CurrentStep.BackButtonConfig
+	} else if userWfTracker.current().BackButtonConfig != nil {
+		return userWfTracker.current().BackButtonConfig
+	} else if userWfTracker.backButtonConfig != nil {
+		return userWfTracker.backButtonConfig
+	}
+	return &BackButtonConfig{
+		backButtonExists: false,
+		backButtonText:   "",
+	}
+}
+
+// moveOn evaluates the next step of the CurrentStep and moves the user to it.
+func (w *TBotWorkflowController) moveOn(ctx context.Context, userWfTracker *workflowTracker, msg *tgbotapi.Message) error {
+	nextStep, err := w.nextStep(ctx, userWfTracker, msg)
+	if err != nil {
+		return err
+	}
+	w.Logger.Printf("Current Step: %s, Next Step: %s", userWfTracker.CurrentStep.Name, nextStep.Name)
+	return w.moveTo(ctx, userWfTracker, nextStep, msg)
+}
+
+// moveTo records the CurrentStep in the history and moves the user to the next step.
+func (w *TBotWorkflowController) moveTo(ctx context.Context, userWfTracker *workflowTracker, next *TBotWorkflowStep, msg *tgbotapi.Message) error {
+	w.onStepExit(userWfTracker, msg)
+	userWfTracker.history = append(userWfTracker.history, userWfTracker.CurrentStep.id())
+	userWfTracker.CurrentStep = next
+	return w.enter(ctx, userWfTracker, msg)
+}
+
+// broken ends the workflow of the user if err wraps ErrBrokenWorkflow.
+// Other errors abort the processing of the message.
+func (w *TBotWorkflowController) broken(userWfTracker *workflowTracker, s *sender,
+	reply tgbotapi.MessageConfig, err error) (*UserInputs, Result, error) {
+	if !errors.Is(err, ErrBrokenWorkflow) {
+		return nil, ResultAborted, err
+	}
+	w.incCounter(MetricBroken, userWfTracker.stepLabels())
+	reply.Text = fmt.Sprintf(w.translate(userWfTracker.userInputs.Language, defaultBrokenWorkflowReplyText),
+		userWfTracker.current().Name, userWfTracker.CurrentStep.Name)
+	reply.ReplyMarkup = tgbotapi.ReplyKeyboardRemove{RemoveKeyboard: true, Selective: true}
+	s.send(reply)
+	s.fail(w.deleteSession(userWfTracker.key))
+	s.fail(err)
+	return nil, ResultBroken, s.err
+}
+
+// goBack moves the user to the previously visited step and removes the input captured at that step.
+// The CurrentStep is repeated if the user is at the first step of the workflow or sub-workflow.
+// Going back to a SubWorkflow step runs the sub-workflow again from its RootStep.
+func (w *TBotWorkflowController) goBack(ctx context.Context, userWfTracker *workflowTracker, msg *tgbotapi.Message) error {
+	if len(userWfTracker.history) == 0 {
+		w.Logger.Printf("Already at first Step: %s. Cannot go back", userWfTracker.CurrentStep.Name)
+		return nil
+	}
+
+	prevID := userWfTracker.history[len(userWfTracker.history)-1]
+	prevStep := userWfTracker.current().FindStep(prevID)
+	if prevStep == nil {
+		w.Logger.Printf("Previous Step: %s not found in Workflow: %s. Cannot go back", prevID, userWfTracker.current().Name)
+		return nil
+	}
+
+	w.Logger.Printf("Current Step: %s, Back to Step: %s", userWfTracker.CurrentStep.Name, prevStep.Name)
+	w.onStepExit(userWfTracker, msg)
+	userWfTracker.history = userWfTracker.history[:len(userWfTracker.history)-1]
+	w.deleteStepInputs(userWfTracker, prevStep)
+	w.recordTransition(userWfTracker, msg, userWfTracker.CurrentStep, prevStep, TransitionBack, "")
+	userWfTracker.CurrentStep = prevStep
+	return w.enter(ctx, userWfTracker, msg)
+}
+
+// deleteStepInputs removes the input captured at the step, or the inputs of the sub-workflow run by the step.
+func (w *TBotWorkflowController) deleteStepInputs(userWfTracker *workflowTracker, step *TBotWorkflowStep) {
+	if step.SubWorkflow != "" {
+		w.deleteSubInputs(userWfTracker, step)
+		return
+	}
+	delete(userWfTracker.userInputs.Data, userWfTracker.dataKey(step.Key))
+	delete(userWfTracker.userInputs.Inputs, userWfTracker.dataKey(step.Key))
+}
+
+func (w *TBotWorkflowController) validateInput(ctx context.Context, msg *tgbotapi.Message,
+	userWfTracker *workflowTracker) (string, bool, error) {
+	step := userWfTracker.CurrentStep
+	lang := userWfTracker.userInputs.Language
+	if !step.acceptsInputKind(messageInputKind(msg)) {
+		return w.getWrongInputKindReplyText(step, lang), false, nil
+	}
+	invalidReplyText, ok, set, err := validateInputContext(ctx, msg, step.KB, step.ValidateInputFunc, step.ValidateInputContextFunc)
+	if err != nil {
+		return "", false, fmt.Errorf("Step: %s: %w", step.Name, err)
+	}
+	if set {
+		return w.translate(lang, invalidReplyText), ok, nil
+	}
+	if step.inputKind() != InputText || messageInputKind(msg) != InputText {
+		// The global function and the default validation only apply to text inputs of text steps.
+		return "", true, nil
+	}
+	invalidReplyText, ok, set, err = validateInputContext(ctx, msg, step.KB, w.ValidateInputFunc, w.ValidateInputContextFunc)
+	if err != nil {
+		return "", false, err
+	}
+	if set {
+		return w.translate(lang, invalidReplyText), ok, nil
+	}
+	if step.InlineKB != nil {
+		invalidReplyText, ok = w.defaultValidateInlineInput(msg, step.InlineKB, lang)
 	} else {
-		invalidReplyText, ok = w.defaultValidateInput(msg, userWfTracker.CurrentStep.KB)
+		invalidReplyText, ok = w.defaultValidateInput(msg, step.KB, lang)
 	}
-	return invalidReplyText, ok
+	return invalidReplyText, ok, nil
 }
@@ -8,8 +8,9 @@ import (
 )
 
 var (
-	mockSendFunc func(c tgbotapi.Chattable) (tgbotapi.Message, error)
-	sentMsgs     []tgbotapi.Message
+	mockSendFunc   func(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	sentMsgs       []tgbotapi.Message
+	sentChattables []tgbotapi.Chattable
 )
 
 type botInteraction struct {
@@ -31,8 +32,8 @@ func TestWorkflows(t *testing.T) {
 	seqWF := newSeqWorkflow("CMD1")
 	condWF := newCondWorkflow("CMD2")
 
-	wfc.AddWorkflow(&seqWF)
-	wfc.AddWorkflow(&condWF)
+	mustAddWorkflow(t, wfc, &seqWF)
+	mustAddWorkflow(t, wfc, &condWF)
 
 	botInteractions := getSeqBotInteractions()
 
@@ -137,6 +138,58 @@ func TestInvalidCommand(t *testing.T) {
 	sentMsgs = []tgbotapi.Message{}
 }
 
+func TestBackButton(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	condWF := newCondWorkflow("CMD2")
+	condWF.BackButtonConfig = NewBackButtonConfig("BACK")
+	mustAddWorkflow(t, wfc, &condWF)
+
+	botInteractions := getCondBotInteractions()
+	for _, bi := range botInteractions[:3] {
+		wfc.Execute(&bi.botMsg, mockSendFunc)
+	}
+
+	// Back from the C1 branch to the conditional step.
+	backMsg := mockBotMessage(1, "BACK")
+	userInput, done := wfc.Execute(&backMsg, mockSendFunc)
+	if done || userInput != nil {
+		t.Fatal("Workflow completed on back button")
+	}
+	expectedMsg := "Please select a condition"
+	if sentMsgs[len(sentMsgs)-1].Text != expectedMsg {
+		t.Errorf("Expected \"%s\" message to be sent. But \"%s\" sent instead", expectedMsg, sentMsgs[len(sentMsgs)-1].Text)
+	}
+
+	// Take the C2 branch instead.
+	for _, text := range []string{"Step2Condition2", "C2Step3Option1"} {
+		msg := mockBotMessage(1, text)
+		userInput, done = wfc.Execute(&msg, mockSendFunc)
+	}
+	if !done || userInput == nil {
+		t.Fatal("Expected workflow to complete")
+	}
+	if len(userInput.Data) != 3 {
+		t.Errorf("Expected 3 user inputs but got %d: %v", len(userInput.Data), userInput.Data)
+	}
+	if userInput.Data["CondK2"] != "Step2Condition2" || userInput.Data["C2K3"] != "C2Step3Option1" {
+		t.Errorf("Expected inputs from the C2 branch but got %v", userInput.Data)
+	}
+	if _, found := userInput.Data["C1K3"]; found {
+		t.Errorf("Expected no inputs from the C1 branch but got %v", userInput.Data)
+	}
+
+	// Back at the first step repeats the first step.
+	cmd := mockBotCommand(1, "/CMD2")
+	wfc.Execute(&cmd, mockSendFunc)
+	wfc.Execute(&backMsg, mockSendFunc)
+	expectedMsg = "Please select an option"
+	if sentMsgs[len(sentMsgs)-1].Text != expectedMsg {
+		t.Errorf("Expected \"%s\" message to be sent. But \"%s\" sent instead", expectedMsg, sentMsgs[len(sentMsgs)-1].Text)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
 func TestBotCommand(t *testing.T) {
 	botCmd := mockBotCommand(1, "/AC")
 	if true != botCmd.IsCommand() {
@@ -176,7 +229,11 @@ func TestMockTelegramSendFunc(t *testing.T) {
 
 func mockTelegramSendFunc() func(c tgbotapi.Chattable) (tgbotapi.Message, error) {
 	return func(c tgbotapi.Chattable) (tgbotapi.Message, error) {
-		msgConfig := c.(tgbotapi.MessageConfig)
+		sentChattables = append(sentChattables, c)
+		msgConfig, ok := c.(tgbotapi.MessageConfig)
+		if !ok {
+			return tgbotapi.Message{}, nil
+		}
 		msg := tgbotapi.Message{
 			Text: msgConfig.Text,
 		}
@@ -186,6 +243,14 @@ func mockTelegramSendFunc() func(c tgbotapi.Chattable) (tgbotapi.Message, error)
 	}
 }
 
+// mustAddWorkflow adds the workflow to the controller and fails the test if it is rejected.
+func mustAddWorkflow(t *testing.T, wfc *TBotWorkflowController, wf *TBotWorkflow) {
+	t.Helper()
+	if err := wfc.AddWorkflow(wf); err != nil {
+		t.Fatalf("Failed adding workflow %s. Error: %v", wf.Name, err)
+	}
+}
+
 func mockBotCommand(chatID int64, text string) tgbotapi.Message {
 	var entities []tgbotapi.MessageEntity
 	entity := tgbotapi.MessageEntity{
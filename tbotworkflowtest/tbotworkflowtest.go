@@ -0,0 +1,373 @@
+// Package tbotworkflowtest provides a fake Telegram chat to test workflows
+// without hand building the messages and the send function.
+//
+// A Chat sends the messages of a user to a TBotWorkflowController, captures the
+// messages the controller sends back and records the conversation as a transcript:
+//
+//	func TestSignup(t *testing.T) {
+//		wfc := tbotworkflow.NewWorkflowController("WFC")
+//		if err := wfc.AddWorkflow(&signupWF); err != nil {
+//			t.Fatal(err)
+//		}
+//
+//		chat := tbotworkflowtest.NewChat(t, wfc)
+//		chat.Send("/signup").ExpectReply("Please enter your name")
+//		chat.Send("Alice").ExpectButtons("Basic", "Pro")
+//		chat.Press("Basic").ExpectResult(tbotworkflow.ResultCompleted).ExpectInput("Plan", "Basic")
+//		chat.ExpectGolden("testdata/signup.golden")
+//	}
+//
+// Golden transcript files are written instead of compared when the
+// TBOTWORKFLOWTEST_UPDATE environment variable is set.
+package tbotworkflowtest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hbbtekademy/tbotworkflow"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// UpdateEnv is the environment variable telling ExpectGolden to write the golden files.
+const UpdateEnv = "TBOTWORKFLOWTEST_UPDATE"
+
+// Chat is a fake private chat between a user and the bot.
+// A Chat is not safe for concurrent use.
+type Chat struct {
+	t   testing.TB
+	wfc *tbotworkflow.TBotWorkflowController
+
+	// User sending the messages. Set LanguageCode to test translated workflows.
+	User tgbotapi.User
+	// Chat the messages are sent in. Set Type to "group" to test chat scoped workflows.
+	Chat tgbotapi.Chat
+	// Context passed to the controller for each message.
+	Context context.Context
+	// All the messages sent by the controller, oldest first.
+	Sent []tgbotapi.Chattable
+
+	replies    []tgbotapi.Chattable
+	inlineMsg  tgbotapi.Message
+	messageID  int
+	userInputs *tbotworkflow.UserInputs
+	result     tbotworkflow.Result
+	err        error
+	transcript []string
+}
+
+// NewChat returns a fake chat of a user with the bot handled by the controller.
+func NewChat(t testing.TB, wfc *tbotworkflow.TBotWorkflowController) *Chat {
+	return &Chat{
+		t:       t,
+		wfc:     wfc,
+		User:    tgbotapi.User{ID: 1, UserName: "user"},
+		Chat:    tgbotapi.Chat{ID: 1, Type: "private"},
+		Context: context.Background(),
+	}
+}
+
+// Send sends a text message. Texts starting with "/" are sent as commands.
+func (c *Chat) Send(text string) *Chat {
+	c.t.Helper()
+	msg := c.newMessage()
+	msg.Text = text
+	if strings.HasPrefix(text, "/") {
+		command := strings.Fields(text)[0]
+		msg.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Length: len(command)}}
+	}
+	return c.execute("> "+text, tgbotapi.Update{Message: msg})
+}
+
+// Press presses a button of the keyboard of the last reply.
+// Inline keyboard buttons are sent as a CallbackQuery with the CallbackData of the button.
+// Fails the test if the last reply does not offer the button.
+func (c *Chat) Press(button string) *Chat {
+	c.t.Helper()
+	if inlineKB, ok := inlineKeyboard(c.lastMarkup()); ok {
+		for _, row := range inlineKB.InlineKeyboard {
+			for _, b := range row {
+				if b.Text != button || b.CallbackData == nil {
+					continue
+				}
+				c.messageID++
+				msg := c.inlineMsg
+				callback := &tgbotapi.CallbackQuery{
+					ID:      fmt.Sprint(c.messageID),
+					From:    &c.User,
+					Message: &msg,
+					Data:    *b.CallbackData,
+				}
+				return c.execute("* "+button, tgbotapi.Update{CallbackQuery: callback})
+			}
+		}
+	}
+	for _, b := range c.Buttons() {
+		if b == button {
+			msg := c.newMessage()
+			msg.Text = button
+			return c.execute("* "+button, tgbotapi.Update{Message: msg})
+		}
+	}
+	c.t.Fatalf("Button %q not offered. Buttons: %q", button, c.Buttons())
+	return c
+}
+
+// SendPhoto sends a photo with a caption.
+func (c *Chat) SendPhoto(fileID string, caption string) *Chat {
+	c.t.Helper()
+	msg := c.newMessage()
+	msg.Photo = []tgbotapi.PhotoSize{{FileID: fileID, FileUniqueID: fileID}}
+	msg.Caption = caption
+	return c.execute("> photo:"+fileID, tgbotapi.Update{Message: msg})
+}
+
+// SendDocument sends a document.
+func (c *Chat) SendDocument(fileID string, fileName string) *Chat {
+	c.t.Helper()
+	msg := c.newMessage()
+	msg.Document = &tgbotapi.Document{FileID: fileID, FileUniqueID: fileID, FileName: fileName}
+	return c.execute("> document:"+fileName, tgbotapi.Update{Message: msg})
+}
+
+// SendLocation sends a location.
+func (c *Chat) SendLocation(latitude float64, longitude float64) *Chat {
+	c.t.Helper()
+	msg := c.newMessage()
+	msg.Location = &tgbotapi.Location{Latitude: latitude, Longitude: longitude}
+	return c.execute(fmt.Sprintf("> location:%v,%v", latitude, longitude), tgbotapi.Update{Message: msg})
+}
+
+// SendContact sends a contact.
+func (c *Chat) SendContact(phoneNumber string, firstName string) *Chat {
+	c.t.Helper()
+	msg := c.newMessage()
+	msg.Contact = &tgbotapi.Contact{PhoneNumber: phoneNumber, FirstName: firstName}
+	return c.execute("> contact:"+phoneNumber, tgbotapi.Update{Message: msg})
+}
+
+// Replies returns the messages sent by the controller for the last message of the user.
+func (c *Chat) Replies() []tgbotapi.Chattable {
+	return c.replies
+}
+
+// LastReply returns the last text message sent by the controller for the last message of the user.
+func (c *Chat) LastReply() (tgbotapi.MessageConfig, bool) {
+	for i := len(c.replies) - 1; i >= 0; i-- {
+		if reply, ok := c.replies[i].(tgbotapi.MessageConfig); ok {
+			return reply, true
+		}
+	}
+	return tgbotapi.MessageConfig{}, false
+}
+
+// Buttons returns the text of the keyboard buttons of the last reply, row by row.
+func (c *Chat) Buttons() []string {
+	return buttons(c.lastMarkup())
+}
+
+// Result returns the Result of the last message of the user.
+func (c *Chat) Result() tbotworkflow.Result {
+	return c.result
+}
+
+// Err returns the error of the last message of the user.
+func (c *Chat) Err() error {
+	return c.err
+}
+
+// UserInputs returns the UserInputs of the last completed workflow, or nil.
+func (c *Chat) UserInputs() *tbotworkflow.UserInputs {
+	return c.userInputs
+}
+
+// Transcript returns the conversation so far, one line per message.
+// Lines of the user start with "> ", or "* " for pressed buttons,
+// lines of the bot start with "< " followed by its keyboard, and
+// results other than in-progress start with "= ".
+func (c *Chat) Transcript() string {
+	return strings.Join(c.transcript, "\n") + "\n"
+}
+
+// ExpectReply checks the text of the last reply.
+func (c *Chat) ExpectReply(text string) *Chat {
+	c.t.Helper()
+	reply, ok := c.LastReply()
+	if !ok {
+		c.t.Errorf("Expected reply %q but got no reply", text)
+	} else if reply.Text != text {
+		c.t.Errorf("Expected reply %q but got %q", text, reply.Text)
+	}
+	return c
+}
+
+// ExpectButtons checks the text of the keyboard buttons of the last reply, row by row.
+func (c *Chat) ExpectButtons(buttons ...string) *Chat {
+	c.t.Helper()
+	got := c.Buttons()
+	equal := len(got) == len(buttons)
+	for i := 0; equal && i < len(got); i++ {
+		equal = got[i] == buttons[i]
+	}
+	if !equal {
+		c.t.Errorf("Expected buttons %q but got %q", buttons, got)
+	}
+	return c
+}
+
+// ExpectResult checks the Result of the last message of the user.
+func (c *Chat) ExpectResult(result tbotworkflow.Result) *Chat {
+	c.t.Helper()
+	if c.result != result {
+		c.t.Errorf("Expected result %v but got %v (error: %v)", result, c.result, c.err)
+	}
+	return c
+}
+
+// ExpectInput checks the user input for the key in the UserInputs of the last completed workflow.
+func (c *Chat) ExpectInput(key string, value string) *Chat {
+	c.t.Helper()
+	if c.userInputs == nil {
+		c.t.Errorf("Expected input %s=%q but the workflow is not completed", key, value)
+	} else if got, found := c.userInputs.Data[key]; !found || got != value {
+		c.t.Errorf("Expected input %s=%q but got %q", key, value, got)
+	}
+	return c
+}
+
+// ExpectGolden compares the Transcript with the golden file at path.
+// The file is written instead when the TBOTWORKFLOWTEST_UPDATE environment variable is set.
+func (c *Chat) ExpectGolden(path string) *Chat {
+	c.t.Helper()
+	transcript := c.Transcript()
+	if os.Getenv(UpdateEnv) != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			c.t.Fatalf("Failed creating golden file directory. Error: %v", err)
+		}
+		if err := ioutil.WriteFile(path, []byte(transcript), 0o644); err != nil {
+			c.t.Fatalf("Failed writing golden file. Error: %v", err)
+		}
+		return c
+	}
+
+	golden, err := ioutil.ReadFile(path)
+	if err != nil {
+		c.t.Fatalf("Failed reading golden file. Set %s=1 to create it. Error: %v", UpdateEnv, err)
+	}
+	if string(golden) != transcript {
+		c.t.Errorf("Transcript does not match %s. Set %s=1 to update it.\nExpected:\n%s\nGot:\n%s",
+			path, UpdateEnv, golden, transcript)
+	}
+	return c
+}
+
+func (c *Chat) newMessage() *tgbotapi.Message {
+	c.messageID++
+	return &tgbotapi.Message{
+		MessageID: c.messageID,
+		From:      &c.User,
+		Chat:      &c.Chat,
+	}
+}
+
+// execute sends the update to the controller and records the exchange.
+func (c *Chat) execute(line string, update tgbotapi.Update) *Chat {
+	c.t.Helper()
+	c.replies = nil
+	c.transcript = append(c.transcript, line)
+	userInputs, result, err := c.wfc.ExecuteUpdateContext(c.Context, update, c.send)
+	c.result, c.err = result, err
+	if userInputs != nil {
+		c.userInputs = userInputs
+	}
+
+	for _, chattable := range c.replies {
+		reply, ok := chattable.(tgbotapi.MessageConfig)
+		if !ok {
+			continue
+		}
+		c.transcript = append(c.transcript, "< "+strings.ReplaceAll(reply.Text, "\n", "\n  "))
+		if b := buttons(reply.ReplyMarkup); len(b) > 0 {
+			c.transcript = append(c.transcript, "  ["+strings.Join(b, "] [")+"]")
+		}
+	}
+	if result != tbotworkflow.ResultInProgress {
+		c.transcript = append(c.transcript, "= "+result.String())
+	}
+	return c
+}
+
+// send is the send function of the controller. It captures the messages and
+// returns them as sent, so that inline keyboards can be pressed.
+func (c *Chat) send(chattable tgbotapi.Chattable) (tgbotapi.Message, error) {
+	c.Sent = append(c.Sent, chattable)
+	c.replies = append(c.replies, chattable)
+	reply, ok := chattable.(tgbotapi.MessageConfig)
+	if !ok {
+		return tgbotapi.Message{}, nil
+	}
+	c.messageID++
+	msg := tgbotapi.Message{
+		MessageID: c.messageID,
+		Chat:      &c.Chat,
+		Text:      reply.Text,
+	}
+	if _, ok := inlineKeyboard(reply.ReplyMarkup); ok {
+		c.inlineMsg = msg
+	}
+	return msg, nil
+}
+
+func (c *Chat) lastMarkup() interface{} {
+	reply, ok := c.LastReply()
+	if !ok {
+		return nil
+	}
+	return reply.ReplyMarkup
+}
+
+func inlineKeyboard(markup interface{}) (tgbotapi.InlineKeyboardMarkup, bool) {
+	switch kb := markup.(type) {
+	case tgbotapi.InlineKeyboardMarkup:
+		return kb, true
+	case *tgbotapi.InlineKeyboardMarkup:
+		if kb != nil {
+			return *kb, true
+		}
+	}
+	return tgbotapi.InlineKeyboardMarkup{}, false
+}
+
+func buttons(markup interface{}) []string {
+	texts := []string{}
+	if inlineKB, ok := inlineKeyboard(markup); ok {
+		for _, row := range inlineKB.InlineKeyboard {
+			for _, b := range row {
+				texts = append(texts, b.Text)
+			}
+		}
+		return texts
+	}
+
+	var kb tgbotapi.ReplyKeyboardMarkup
+	switch k := markup.(type) {
+	case tgbotapi.ReplyKeyboardMarkup:
+		kb = k
+	case *tgbotapi.ReplyKeyboardMarkup:
+		if k != nil {
+			kb = *k
+		}
+	}
+	for _, row := range kb.Keyboard {
+		for _, b := range row {
+			texts = append(texts, b.Text)
+		}
+	}
+	return texts
+}
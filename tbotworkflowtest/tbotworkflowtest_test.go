@@ -0,0 +1,67 @@
+package tbotworkflowtest
+
+import (
+	"testing"
+
+	"github.com/hbbtekademy/tbotworkflow"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func newProfileWorkflow() *tbotworkflow.TBotWorkflow {
+	planKB := tgbotapi.NewReplyKeyboard(tgbotapi.NewKeyboardButtonRow(
+		tgbotapi.NewKeyboardButton("Basic"), tgbotapi.NewKeyboardButton("Pro")))
+	name := tbotworkflow.NewWorkflowStep("Name", "Name", "Please enter your name", nil)
+	plan := tbotworkflow.NewWorkflowStep("Plan", "Plan", "Please select a plan", &planKB)
+	colorKB := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Red", "red"), tgbotapi.NewInlineKeyboardButtonData("Blue", "blue")))
+	color := tbotworkflow.NewWorkflowStep("Color", "Color", "Please pick a color", nil)
+	color.InlineKB = &colorKB
+	avatar := tbotworkflow.NewWorkflowStep("Avatar", "Avatar", "Please send a photo", nil)
+	avatar.InputKind = tbotworkflow.InputPhoto
+	done := tbotworkflow.NewWorkflowStep("Done", "", "Profile saved", nil)
+	name.Next = &plan
+	plan.Next = &color
+	color.Next = &avatar
+	avatar.Next = &done
+
+	wf := tbotworkflow.NewWorkflow("ProfileWF", "PROFILE", &name)
+	return &wf
+}
+
+func TestChat(t *testing.T) {
+	wfc := tbotworkflow.NewWorkflowController("WFC")
+	if err := wfc.AddWorkflow(newProfileWorkflow()); err != nil {
+		t.Fatalf("Failed adding workflow. Error: %v", err)
+	}
+
+	chat := NewChat(t, wfc)
+	chat.Send("/profile").ExpectReply("Please enter your name").ExpectResult(tbotworkflow.ResultInProgress)
+	chat.Send("Alice").ExpectReply("Please select a plan").ExpectButtons("Basic", "Pro")
+	chat.Send("Gold").ExpectResult(tbotworkflow.ResultValidationFailed)
+	chat.Press("Pro").ExpectReply("Please pick a color").ExpectButtons("Red", "Blue")
+	chat.Press("Blue").ExpectReply("Please send a photo")
+	chat.SendPhoto("photo-1", "Me").ExpectReply("Profile saved").ExpectResult(tbotworkflow.ResultCompleted)
+	chat.ExpectInput("Name", "Alice").ExpectInput("Plan", "Pro").ExpectInput("Color", "blue")
+	if avatar, err := chat.UserInputs().File("Avatar"); err != nil || avatar.FileID != "photo-1" {
+		t.Errorf("Expected the photo input but got %v/%v", avatar, err)
+	}
+	chat.ExpectGolden("testdata/profile.golden")
+}
+
+func TestChatUnknownButton(t *testing.T) {
+	wfc := tbotworkflow.NewWorkflowController("WFC")
+	if err := wfc.AddWorkflow(newProfileWorkflow()); err != nil {
+		t.Fatalf("Failed adding workflow. Error: %v", err)
+	}
+
+	chat := NewChat(t, wfc)
+	chat.Send("/profile").Send("Alice")
+	if buttons := chat.Buttons(); len(buttons) != 2 || buttons[1] != "Pro" {
+		t.Errorf("Expected the plan buttons but got %v", buttons)
+	}
+	chat.Send("/unknown").ExpectResult(tbotworkflow.ResultNotFound)
+	if chat.Err() == nil {
+		t.Error("Expected workflow not found error")
+	}
+}
@@ -0,0 +1,298 @@
+package tbotworkflow
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Types of the events of a transcript.
+const (
+	// TranscriptMessage is a message or CallbackQuery received from the user.
+	TranscriptMessage string = "message"
+	// TranscriptTransition is the user moving from a step to another.
+	TranscriptTransition string = "transition"
+	// TranscriptValidation is the outcome of the validation of the user input.
+	TranscriptValidation string = "validation"
+	// TranscriptReply is a text message sent to the user.
+	TranscriptReply string = "reply"
+	// TranscriptResult is the Result of the message received from the user.
+	TranscriptResult string = "result"
+)
+
+// Transitions of the TranscriptTransition events.
+const (
+	// TransitionNext is a move to the Next step.
+	TransitionNext string = "next"
+	// TransitionConditionalNext is a move to a ConditionalNext step. Condition is the output of the ConditionFunc.
+	TransitionConditionalNext string = "conditionalNext"
+	// TransitionBack is a move to the previous step with the back button.
+	TransitionBack string = "back"
+	// TransitionEdit is a move from a summary step to the step to edit.
+	TransitionEdit string = "edit"
+	// TransitionSummary is a move from an edited step back to its summary step.
+	TransitionSummary string = "summary"
+)
+
+// TranscriptEvent is an event of the conversations handled by the controller.
+// Fields not relevant to the Type of the event are left empty.
+type TranscriptEvent struct {
+	// Time of the event.
+	Time time.Time `json:"time"`
+	// Type of the event, e.g. TranscriptMessage.
+	Type string `json:"type"`
+	// Telegram Chat ID
+	ChatID int64 `json:"chatId"`
+	// Telegram User ID
+	UID int64 `json:"uid,omitempty"`
+	// Command of the workflow the event belongs to.
+	Command string `json:"command,omitempty"`
+	// ID of the step the event belongs to.
+	Step string `json:"step,omitempty"`
+	// Message received from the user.
+	Message *tgbotapi.Message `json:"message,omitempty"`
+	// CallbackQuery received from the user.
+	Callback *tgbotapi.CallbackQuery `json:"callback,omitempty"`
+	// ID of the step the user moved to, e.g. TransitionNext.
+	To string `json:"to,omitempty"`
+	// Kind of transition.
+	Transition string `json:"transition,omitempty"`
+	// Output of the ConditionFunc for TransitionConditionalNext.
+	Condition string `json:"condition,omitempty"`
+	// Tells if the user input is valid.
+	Valid bool `json:"valid,omitempty"`
+	// Text sent to the user for a reply or an invalid input.
+	Text string `json:"text,omitempty"`
+	// Keyboard buttons of a reply.
+	Buttons []string `json:"buttons,omitempty"`
+	// Result of the message.
+	Result string `json:"result,omitempty"`
+	// Error returned for the message.
+	Error string `json:"error,omitempty"`
+}
+
+// TranscriptRecorder records the events of the conversations handled by the controller,
+// e.g. to find out which steps a user went through.
+// Record is called while the message is processed and must be safe for concurrent use.
+type TranscriptRecorder interface {
+	Record(event TranscriptEvent)
+}
+
+// JSONTranscriptRecorder is a TranscriptRecorder writing each event as a line of JSON.
+type JSONTranscriptRecorder struct {
+	m       sync.Mutex
+	encoder *json.Encoder
+	// Function called when an event cannot be written.
+	OnError func(err error)
+}
+
+// NewJSONTranscriptRecorder returns a pointer to a recorder writing to w.
+func NewJSONTranscriptRecorder(w io.Writer) *JSONTranscriptRecorder {
+	return &JSONTranscriptRecorder{encoder: json.NewEncoder(w)}
+}
+
+// Record writes the event as a line of JSON.
+func (r *JSONTranscriptRecorder) Record(event TranscriptEvent) {
+	r.m.Lock()
+	defer r.m.Unlock()
+	if err := r.encoder.Encode(event); err != nil && r.OnError != nil {
+		r.OnError(err)
+	}
+}
+
+// ReadTranscript reads the events written by a JSONTranscriptRecorder.
+func ReadTranscript(r io.Reader) ([]TranscriptEvent, error) {
+	events := []TranscriptEvent{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		event := TranscriptEvent{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("failed decoding transcript line %d: %w", line, err)
+		}
+		events = append(events, event)
+	}
+	return events, scanner.Err()
+}
+
+// ReplayMismatchError is returned by Replay when the controller does not take the recorded path.
+type ReplayMismatchError struct {
+	// Index of the event in the transcript. For extra events, index of the event they were expected before.
+	Index int
+	// Event of the transcript. Zero if the controller produced more events than recorded.
+	Expected TranscriptEvent
+	// Event produced by the controller. Zero if the controller produced fewer events than recorded.
+	Got TranscriptEvent
+}
+
+func (e *ReplayMismatchError) Error() string {
+	return fmt.Sprintf("transcript event %d: expected %s but got %s", e.Index, e.Expected.summary(), e.Got.summary())
+}
+
+// Replay feeds the messages of a transcript back into the controller and checks
+// that the controller goes through the same transitions, validations, replies and results.
+// The clock of the idle timeouts is set to the time each message was recorded,
+// so that the path is reproduced deterministically. Transcripts can mix the events of several chats.
+// Replay into a controller with the same workflows and an empty session store,
+// and without processing other messages while replaying.
+// Returns a ReplayMismatchError at the first event which differs from the transcript.
+func (w *TBotWorkflowController) Replay(ctx context.Context, events []TranscriptEvent, sendFunc SendFunc) error {
+	recorded := &transcriptCollector{}
+	recorder, now := w.Recorder, w.now
+	w.Recorder = recorded
+	defer func() { w.Recorder, w.now = recorder, now }()
+
+	for i, event := range events {
+		if event.Type != TranscriptMessage {
+			continue
+		}
+		update := tgbotapi.Update{Message: event.Message, CallbackQuery: event.Callback}
+		eventTime := event.Time
+		w.now = func() time.Time { return eventTime }
+		recorded.events = nil
+		w.ExecuteUpdateContext(ctx, update, sendFunc)
+		if len(recorded.events) == 0 {
+			// The update was ignored by the controller.
+			return &ReplayMismatchError{Index: i, Expected: event}
+		}
+
+		// The events of the message are the next events of the same chat, up to its next message.
+		got := recorded.events[1:]
+		j := i + 1
+		for ; j < len(events); j++ {
+			expected := events[j]
+			if expected.ChatID != event.ChatID {
+				continue
+			}
+			if expected.Type == TranscriptMessage {
+				break
+			}
+			if len(got) == 0 {
+				return &ReplayMismatchError{Index: j, Expected: expected}
+			}
+			if !got[0].sameAs(expected) {
+				return &ReplayMismatchError{Index: j, Expected: expected, Got: got[0]}
+			}
+			got = got[1:]
+		}
+		if len(got) > 0 {
+			return &ReplayMismatchError{Index: j, Got: got[0]}
+		}
+	}
+	return nil
+}
+
+// transcriptCollector collects the events recorded while replaying.
+type transcriptCollector struct {
+	events []TranscriptEvent
+}
+
+func (r *transcriptCollector) Record(event TranscriptEvent) {
+	r.events = append(r.events, event)
+}
+
+// sameAs tells if the events are the same, ignoring the time.
+func (e TranscriptEvent) sameAs(o TranscriptEvent) bool {
+	if len(e.Buttons) != len(o.Buttons) {
+		return false
+	}
+	for i := range e.Buttons {
+		if e.Buttons[i] != o.Buttons[i] {
+			return false
+		}
+	}
+	return e.Type == o.Type && e.ChatID == o.ChatID && e.UID == o.UID && e.Command == o.Command && e.Step == o.Step &&
+		e.To == o.To && e.Transition == o.Transition && e.Condition == o.Condition && e.Valid == o.Valid &&
+		e.Text == o.Text && e.Result == o.Result && e.Error == o.Error
+}
+
+// summary returns a short description of the event for error messages.
+func (e TranscriptEvent) summary() string {
+	switch e.Type {
+	case "":
+		return "no event"
+	case TranscriptTransition:
+		return fmt.Sprintf("%s %s from %s to %s", e.Type, e.Transition, e.Step, e.To)
+	case TranscriptValidation:
+		return fmt.Sprintf("%s at %s valid=%t", e.Type, e.Step, e.Valid)
+	case TranscriptReply:
+		return fmt.Sprintf("%s %q", e.Type, e.Text)
+	case TranscriptResult:
+		return fmt.Sprintf("%s %s", e.Type, e.Result)
+	}
+	return e.Type
+}
+
+// record sends the event of the message to the Recorder, if any.
+func (w *TBotWorkflowController) record(msg *tgbotapi.Message, event TranscriptEvent) {
+	if w.Recorder == nil {
+		return
+	}
+	event.Time = time.Now()
+	if w.now != nil {
+		event.Time = w.now()
+	}
+	event.ChatID = msg.Chat.ID
+	if msg.From != nil {
+		event.UID = msg.From.ID
+	}
+	w.Recorder.Record(event)
+}
+
+// recordTransition records the move of the user from a step to another.
+func (w *TBotWorkflowController) recordTransition(t *workflowTracker, msg *tgbotapi.Message,
+	from *TBotWorkflowStep, to *TBotWorkflowStep, transition string, condition string) {
+	w.record(msg, TranscriptEvent{
+		Type:       TranscriptTransition,
+		Command:    t.Command,
+		Step:       from.id(),
+		To:         to.id(),
+		Transition: transition,
+		Condition:  condition,
+	})
+}
+
+// recordingSend returns a send function recording the text messages sent to the user.
+func (w *TBotWorkflowController) recordingSend(msg *tgbotapi.Message, sendFunc SendFunc) SendFunc {
+	return func(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+		if reply, ok := c.(tgbotapi.MessageConfig); ok {
+			w.record(msg, TranscriptEvent{Type: TranscriptReply, Text: reply.Text, Buttons: replyButtons(reply.ReplyMarkup)})
+		}
+		return sendFunc(c)
+	}
+}
+
+// replyButtons returns the text of the keyboard buttons of a reply, row by row.
+func replyButtons(markup interface{}) []string {
+	var texts []string
+	switch kb := markup.(type) {
+	case *tgbotapi.ReplyKeyboardMarkup:
+		if kb == nil {
+			return nil
+		}
+		for _, row := range kb.Keyboard {
+			for _, button := range row {
+				texts = append(texts, button.Text)
+			}
+		}
+	case *tgbotapi.InlineKeyboardMarkup:
+		if kb == nil {
+			return nil
+		}
+		for _, row := range kb.InlineKeyboard {
+			for _, button := range row {
+				texts = append(texts, button.Text)
+			}
+		}
+	}
+	return texts
+}
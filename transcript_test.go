@@ -0,0 +1,103 @@
+package tbotworkflow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func recordSignup(t *testing.T) []TranscriptEvent {
+	var buf bytes.Buffer
+	wfc := NewWorkflowController("WFC")
+	wfc.Recorder = NewJSONTranscriptRecorder(&buf)
+	signupWF := newSignupWorkflow("SIGNUP")
+	mustAddWorkflow(t, wfc, &signupWF)
+
+	runMessages(wfc, 1, "/SIGNUP", "Alice")
+	runMessages(wfc, 2, "/SIGNUP")
+	runMessages(wfc, 1, "a@example.com", "Gold", "Pro", "5", "Confirm")
+	runMessages(wfc, 2, "Bob", "BACK")
+
+	events, err := ReadTranscript(&buf)
+	if err != nil {
+		t.Fatalf("Failed reading transcript. Error: %v", err)
+	}
+	return events
+}
+
+func TestTranscript(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	events := recordSignup(t)
+
+	var transitions, invalid, results []TranscriptEvent
+	for _, event := range events {
+		if event.ChatID != 1 {
+			continue
+		}
+		switch {
+		case event.Type == TranscriptTransition:
+			transitions = append(transitions, event)
+		case event.Type == TranscriptValidation && !event.Valid:
+			invalid = append(invalid, event)
+		case event.Type == TranscriptResult:
+			results = append(results, event)
+		}
+	}
+
+	if len(transitions) != 5 || transitions[0].Transition != TransitionNext || transitions[0].To != "Email" {
+		t.Fatalf("Expected the transitions of the workflow but got %+v", transitions)
+	}
+	if pro := transitions[2]; pro.Transition != TransitionConditionalNext || pro.Condition != "Pro" ||
+		pro.Step != "Plan" || pro.To != "Seats" || pro.Command != "SIGNUP" {
+		t.Errorf("Expected the ConditionalNext transition but got %+v", pro)
+	}
+	if len(invalid) != 1 || invalid[0].Step != "Plan" || invalid[0].Text != "Invalid input Gold. Please try again" {
+		t.Errorf("Expected the failed validation but got %+v", invalid)
+	}
+	if len(results) != 7 || results[3].Result != "validation-failed" || results[6].Result != "completed" {
+		t.Errorf("Expected the results of the messages but got %+v", results)
+	}
+	if events[0].Type != TranscriptMessage || events[0].Message == nil || events[0].Message.Text != "/SIGNUP" {
+		t.Errorf("Expected the message of the user first but got %+v", events[0])
+	}
+	for _, event := range events {
+		if event.Type == TranscriptReply && event.Text == "Please select a plan" &&
+			(len(event.Buttons) != 2 || event.Buttons[1] != "Pro") {
+			t.Errorf("Expected the buttons of the reply but got %+v", event)
+		}
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestReplay(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	events := recordSignup(t)
+
+	wfc := NewWorkflowController("WFC")
+	signupWF := newSignupWorkflow("SIGNUP")
+	mustAddWorkflow(t, wfc, &signupWF)
+	if err := wfc.Replay(context.Background(), events, mockSendFunc); err != nil {
+		t.Errorf("Expected the transcript to replay but got %v", err)
+	}
+
+	// A workflow which changed takes another path.
+	wfc = NewWorkflowController("WFC")
+	changedWF := newSignupWorkflow("SIGNUP")
+	changedWF.RootStep.Next.Next.ConditionalNext["Pro"] = changedWF.RootStep.Next.Next.ConditionalNext["Basic"]
+	mustAddWorkflow(t, wfc, &changedWF)
+	err := wfc.Replay(context.Background(), events, mockSendFunc)
+	var mismatch *ReplayMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected ReplayMismatchError but got %v", err)
+	}
+	if mismatch.Expected.To != "Seats" || mismatch.Got.To != "Review" {
+		t.Errorf("Expected the mismatch at the ConditionalNext transition but got %v", mismatch)
+	}
+	if wfc.Recorder != nil {
+		t.Error("Expected the Recorder of the controller restored")
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
@@ -0,0 +1,226 @@
+package tbotworkflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// WorkflowValidationError lists all the problems found in the steps of a workflow.
+type WorkflowValidationError struct {
+	// Name of the invalid workflow.
+	Workflow string
+	// Description of each problem found.
+	Problems []string
+}
+
+func (e *WorkflowValidationError) Error() string {
+	return fmt.Sprintf("workflow %s is invalid: %s", e.Workflow, strings.Join(e.Problems, "; "))
+}
+
+// Validate checks the steps of the workflow for problems that would otherwise
+// only show up while users are going through the workflow:
+// a nil RootStep, cycles the user can never leave, Next or ConditionalNext steps that can never be reached,
+// a ConditionFunc without ConditionalNext steps, steps sharing the same explicitly set ID,
+// a ValueType that cannot be parsed from the InputKind of the step,
+// and steps with the same Name or Key on one path.
+// Returns a *WorkflowValidationError if any problem is found.
+func (wf *TBotWorkflow) Validate() error {
+	if wf.RootStep == nil {
+		return &WorkflowValidationError{Workflow: wf.Name, Problems: []string{"RootStep is nil"}}
+	}
+
+	problems := []string{}
+	addProblem := func(format string, a ...interface{}) {
+		problem := fmt.Sprintf(format, a...)
+		for _, p := range problems {
+			if p == problem {
+				return
+			}
+		}
+		problems = append(problems, problem)
+	}
+
+	steps := wf.steps()
+	ids := make(map[string]*TBotWorkflowStep)
+	for _, step := range steps {
+		// Steps without ID get a unique default ID when the workflow is added to a controller.
+		if other, found := ids[step.ID]; found && other != step {
+			addProblem("steps share the ID %q", step.ID)
+		}
+		if step.ID != "" {
+			ids[step.ID] = step
+		}
+
+		if step.hasCondition() {
+			if len(step.ConditionalNext) == 0 {
+				addProblem("step %s has a ConditionFunc but no ConditionalNext steps", step.Name)
+			}
+			if step.Next != nil && !step.hasConditionalNext(step.Next) {
+				addProblem("Next step %s of step %s is unreachable since ConditionFunc is set", step.Next.Name, step.Name)
+			}
+		} else if len(step.ConditionalNext) > 0 {
+			addProblem("ConditionalNext steps of step %s are unreachable since ConditionFunc is not set", step.Name)
+		}
+		for _, cond := range step.conditions() {
+			if step.ConditionalNext[cond] == nil {
+				addProblem("step %s has a nil ConditionalNext step for condition %q", step.Name, cond)
+			}
+		}
+		if step.Repeat != nil {
+			if step.isLastStep() {
+				addProblem("step %s is repeatable but is the last step", step.Name)
+			}
+			if step.SubWorkflow != "" {
+				addProblem("step %s runs a SubWorkflow and cannot be repeatable", step.Name)
+			}
+			if step.Repeat.max > 0 && step.Repeat.min > step.Repeat.max {
+				addProblem("step %s requires at least %d inputs but accepts at most %d", step.Name, step.Repeat.min, step.Repeat.max)
+			}
+		}
+		if step.Summary != nil {
+			if step.isLastStep() {
+				addProblem("summary step %s is the last step", step.Name)
+			}
+			if step.SubWorkflow != "" || step.Repeat != nil {
+				addProblem("summary step %s cannot run a SubWorkflow or be repeatable", step.Name)
+			}
+		}
+
+		switch step.valueType() {
+		case ValueString, ValueInt, ValueFloat, ValueBool, ValueTime, ValueEnum, ValueEmail:
+		case ValuePhone:
+			if kind := step.inputKind(); kind != InputText && kind != InputContact {
+				addProblem("step %s has ValueType phone but InputKind %s", step.Name, kind)
+			}
+		case ValueFile:
+			if kind := step.inputKind(); kind == InputText || kind == InputLocation || kind == InputContact {
+				addProblem("step %s has ValueType file but InputKind %s", step.Name, kind)
+			}
+		default:
+			addProblem("step %s has unknown ValueType %q", step.Name, step.ValueType)
+		}
+	}
+
+	// Every step must lead to a last step, otherwise the user is stuck in a cycle.
+	canFinish := make(map[*TBotWorkflowStep]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, step := range steps {
+			if canFinish[step] {
+				continue
+			}
+			finishes := step.isLastStep()
+			for _, next := range step.nextSteps() {
+				finishes = finishes || canFinish[next]
+			}
+			if finishes {
+				canFinish[step] = true
+				changed = true
+			}
+		}
+	}
+	for _, step := range steps {
+		if !canFinish[step] {
+			addProblem("step %s is part of a cycle without exit", step.Name)
+		}
+	}
+
+	// Steps with the same Name or Key on one path overwrite each other's inputs.
+	// Two steps are on one path if one can be reached from the other.
+	// Each pair is reported once, the step closer to the RootStep first.
+	reported := make(map[[2]*TBotWorkflowStep]bool)
+	for _, step := range steps {
+		for _, other := range step.reachableSteps() {
+			if other == step || reported[[2]*TBotWorkflowStep{other, step}] {
+				continue
+			}
+			reported[[2]*TBotWorkflowStep{step, other}] = true
+			if other.Name == step.Name {
+				addProblem("steps %s and %s on one path share the Name %q", step.id(), other.id(), step.Name)
+			}
+			if other.Key == step.Key && step.Key != "" {
+				addProblem("steps %s and %s on one path share the Key %q", step.Name, other.Name, step.Key)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &WorkflowValidationError{Workflow: wf.Name, Problems: problems}
+	}
+	return nil
+}
+
+// steps returns all the steps reachable from the RootStep in breadth first order.
+func (wf *TBotWorkflow) steps() []*TBotWorkflowStep {
+	steps := []*TBotWorkflowStep{}
+	visited := make(map[*TBotWorkflowStep]bool)
+	queue := []*TBotWorkflowStep{wf.RootStep}
+	for len(queue) > 0 {
+		step := queue[0]
+		queue = queue[1:]
+		if step == nil || visited[step] {
+			continue
+		}
+		visited[step] = true
+		steps = append(steps, step)
+		queue = append(queue, step.nextSteps()...)
+	}
+	return steps
+}
+
+// reachableSteps returns the steps that can be reached from this step in breadth first order.
+// The step itself is included only if it is part of a cycle.
+func (s *TBotWorkflowStep) reachableSteps() []*TBotWorkflowStep {
+	steps := []*TBotWorkflowStep{}
+	visited := make(map[*TBotWorkflowStep]bool)
+	queue := s.nextSteps()
+	for len(queue) > 0 {
+		step := queue[0]
+		queue = queue[1:]
+		if visited[step] {
+			continue
+		}
+		visited[step] = true
+		steps = append(steps, step)
+		queue = append(queue, step.nextSteps()...)
+	}
+	return steps
+}
+
+// nextSteps returns the steps that can follow this step, ConditionalNext steps sorted by condition.
+func (s *TBotWorkflowStep) nextSteps() []*TBotWorkflowStep {
+	if !s.hasCondition() {
+		if s.Next == nil {
+			return nil
+		}
+		return []*TBotWorkflowStep{s.Next}
+	}
+
+	next := []*TBotWorkflowStep{}
+	for _, cond := range s.conditions() {
+		if s.ConditionalNext[cond] != nil {
+			next = append(next, s.ConditionalNext[cond])
+		}
+	}
+	return next
+}
+
+// conditions returns the sorted ConditionalNext keys.
+func (s *TBotWorkflowStep) conditions() []string {
+	conds := make([]string, 0, len(s.ConditionalNext))
+	for cond := range s.ConditionalNext {
+		conds = append(conds, cond)
+	}
+	sort.Strings(conds)
+	return conds
+}
+
+func (s *TBotWorkflowStep) hasConditionalNext(step *TBotWorkflowStep) bool {
+	for _, next := range s.ConditionalNext {
+		if next == step {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,250 @@
+package tbotworkflow
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestValidate(t *testing.T) {
+	condFunc := func(msg *tgbotapi.Message) string { return msg.Text }
+
+	tests := []struct {
+		name            string
+		wf              func() TBotWorkflow
+		expectedProblem string
+	}{
+		{
+			name:            "Sequential",
+			wf:              func() TBotWorkflow { return newSeqWorkflow("CMD1") },
+			expectedProblem: "",
+		},
+		{
+			name:            "Conditional",
+			wf:              func() TBotWorkflow { return newCondWorkflow("CMD2") },
+			expectedProblem: "",
+		},
+		{
+			name:            "NilRoot",
+			wf:              func() TBotWorkflow { return NewWorkflow("WF", "CMD", nil) },
+			expectedProblem: "RootStep is nil",
+		},
+		{
+			name: "CycleWithoutExit",
+			wf: func() TBotWorkflow {
+				step1 := NewWorkflowStep("Step1", "K1", "Text", nil)
+				step2 := NewWorkflowStep("Step2", "K2", "Text", nil)
+				step1.Next = &step2
+				step2.Next = &step1
+				return NewWorkflow("WF", "CMD", &step1)
+			},
+			expectedProblem: "step Step1 is part of a cycle without exit",
+		},
+		{
+			name: "ConditionFuncWithoutConditionalNext",
+			wf: func() TBotWorkflow {
+				step1 := NewWorkflowStep("Step1", "K1", "Text", nil)
+				step1.ConditionFunc = condFunc
+				return NewWorkflow("WF", "CMD", &step1)
+			},
+			expectedProblem: "step Step1 has a ConditionFunc but no ConditionalNext steps",
+		},
+		{
+			name: "UnreachableNext",
+			wf: func() TBotWorkflow {
+				step1 := NewWorkflowStep("Step1", "K1", "Text", nil)
+				step2 := NewWorkflowStep("Step2", "", "Text", nil)
+				step3 := NewWorkflowStep("Step3", "", "Text", nil)
+				step1.ConditionFunc = condFunc
+				step1.ConditionalNext["C1"] = &step2
+				step1.Next = &step3
+				return NewWorkflow("WF", "CMD", &step1)
+			},
+			expectedProblem: "Next step Step3 of step Step1 is unreachable since ConditionFunc is set",
+		},
+		{
+			name: "UnreachableConditionalNext",
+			wf: func() TBotWorkflow {
+				step1 := NewWorkflowStep("Step1", "K1", "Text", nil)
+				step2 := NewWorkflowStep("Step2", "", "Text", nil)
+				step1.ConditionalNext["C1"] = &step2
+				return NewWorkflow("WF", "CMD", &step1)
+			},
+			expectedProblem: "ConditionalNext steps of step Step1 are unreachable since ConditionFunc is not set",
+		},
+		{
+			name: "DuplicateKeyOnPath",
+			wf: func() TBotWorkflow {
+				step1 := NewWorkflowStep("Step1", "K1", "Text", nil)
+				step2 := NewWorkflowStep("Step2", "K1", "Text", nil)
+				step3 := NewWorkflowStep("Step3", "", "Text", nil)
+				step1.Next = &step2
+				step2.Next = &step3
+				return NewWorkflow("WF", "CMD", &step1)
+			},
+			expectedProblem: "steps Step1 and Step2 on one path share the Key \"K1\"",
+		},
+		{
+			name: "DuplicateNameOnPath",
+			wf: func() TBotWorkflow {
+				step1 := NewWorkflowStep("Step", "K1", "Text", nil)
+				step1.ID = "S1"
+				step2 := NewWorkflowStep("Step", "K2", "Text", nil)
+				step2.ID = "S2"
+				step1.Next = &step2
+				return NewWorkflow("WF", "CMD", &step1)
+			},
+			expectedProblem: "steps S1 and S2 on one path share the Name \"Step\"",
+		},
+		{
+			name: "DuplicateID",
+			wf: func() TBotWorkflow {
+				step1 := NewWorkflowStep("Step1", "K1", "Text", nil)
+				step2 := NewWorkflowStep("Last1", "", "Text", nil)
+				step3 := NewWorkflowStep("Last2", "", "Text", nil)
+				step2.ID = "Last"
+				step3.ID = "Last"
+				step1.ConditionFunc = condFunc
+				step1.ConditionalNext["C1"] = &step2
+				step1.ConditionalNext["C2"] = &step3
+				return NewWorkflow("WF", "CMD", &step1)
+			},
+			expectedProblem: "steps share the ID \"Last\"",
+		},
+		{
+			name: "FileValueFromText",
+			wf: func() TBotWorkflow {
+				step1 := NewWorkflowStep("Step1", "K1", "Text", nil)
+				step1.ValueType = ValueFile
+				return NewWorkflow("WF", "CMD", &step1)
+			},
+			expectedProblem: "step Step1 has ValueType file but InputKind text",
+		},
+		{
+			name: "RepeatableLastStep",
+			wf: func() TBotWorkflow {
+				step1 := NewWorkflowStep("Step1", "K1", "Text", nil)
+				step1.Repeat = NewRepeatConfig("Done", 0, 0)
+				return NewWorkflow("WF", "CMD", &step1)
+			},
+			expectedProblem: "step Step1 is repeatable but is the last step",
+		},
+		{
+			name: "RepeatMinAboveMax",
+			wf: func() TBotWorkflow {
+				step1 := NewWorkflowStep("Step1", "K1", "Text", nil)
+				step2 := NewWorkflowStep("Step2", "", "Text", nil)
+				step1.Repeat = NewRepeatConfig("Done", 3, 2)
+				step1.Next = &step2
+				return NewWorkflow("WF", "CMD", &step1)
+			},
+			expectedProblem: "step Step1 requires at least 3 inputs but accepts at most 2",
+		},
+		{
+			name: "SummaryLastStep",
+			wf: func() TBotWorkflow {
+				step1 := NewWorkflowStep("Step1", "K1", "Text", nil)
+				step2 := NewWorkflowStep("Step2", "", "Text", nil)
+				step2.Summary = NewSummaryConfig("Confirm", "Edit %s")
+				step1.Next = &step2
+				return NewWorkflow("WF", "CMD", &step1)
+			},
+			expectedProblem: "summary step Step2 is the last step",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			wf := tc.wf()
+			err := wf.Validate()
+			if tc.expectedProblem == "" {
+				if err != nil {
+					t.Errorf("Expected workflow to be valid but got %v", err)
+				}
+				return
+			}
+
+			var validationErr *WorkflowValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("Expected WorkflowValidationError but got %v", err)
+			}
+			found := false
+			for _, problem := range validationErr.Problems {
+				found = found || problem == tc.expectedProblem
+			}
+			if !found {
+				t.Errorf("Expected problem \"%s\" but got %v", tc.expectedProblem, validationErr.Problems)
+			}
+		})
+	}
+}
+
+func TestValidateRejoiningBranches(t *testing.T) {
+	// 30 yes/no questions whose branches rejoin before the next question.
+	last := NewWorkflowStep("Last", "", "Text", nil)
+	next := &last
+	for i := 30; i > 0; i-- {
+		yes := NewWorkflowStep(fmt.Sprintf("Yes%d", i), fmt.Sprintf("Yes%d", i), "Text", nil)
+		no := NewWorkflowStep(fmt.Sprintf("No%d", i), fmt.Sprintf("No%d", i), "Text", nil)
+		question := NewWorkflowStep(fmt.Sprintf("Question%d", i), fmt.Sprintf("Question%d", i), "Text", nil)
+		yes.Next, no.Next = next, next
+		question.ConditionFunc = func(msg *tgbotapi.Message) string { return msg.Text }
+		question.ConditionalNext["Yes"] = &yes
+		question.ConditionalNext["No"] = &no
+		next = &question
+	}
+	wf := NewWorkflow("WF", "CMD", next)
+
+	start := time.Now()
+	if err := wf.Validate(); err != nil {
+		t.Errorf("Expected workflow to be valid but got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected validation to finish quickly but took %v", elapsed)
+	}
+
+	// A duplicate Key after the branches rejoin is still found.
+	last.Key = "Yes1"
+	err := wf.Validate()
+	if err == nil || !strings.Contains(err.Error(), "steps Yes1 and Last on one path share the Key \"Yes1\"") {
+		t.Errorf("Expected duplicate Key problem but got %v", err)
+	}
+}
+
+func TestAddWorkflow(t *testing.T) {
+	wfc := NewWorkflowController("WFC")
+	seqWF := newSeqWorkflow("CMD1")
+	condWF := newCondWorkflow("CMD1")
+
+	if err := wfc.AddWorkflow(&seqWF); err != nil {
+		t.Fatalf("Failed adding workflow. Error: %v", err)
+	}
+	if err := wfc.AddWorkflow(&seqWF); err != nil {
+		t.Errorf("Adding the same workflow twice should not fail. Error: %v", err)
+	}
+	err := wfc.AddWorkflow(&condWF)
+	if err == nil || !strings.Contains(err.Error(), "already registered for Command: CMD1") {
+		t.Errorf("Expected duplicate Command error but got %v", err)
+	}
+
+	brokenWF := NewWorkflow("Broken", "CMD2", nil)
+	if err := wfc.AddWorkflow(&brokenWF); err == nil {
+		t.Error("Expected invalid workflow to be rejected")
+	}
+
+	// Steps sharing their Name on different branches are not on one path.
+	step1 := NewWorkflowStep("Step1", "K1", "Text", nil)
+	step2 := NewWorkflowStep("Confirm", "K2", "Text", nil)
+	step3 := NewWorkflowStep("Confirm", "K3", "Text", nil)
+	step1.ConditionFunc = func(msg *tgbotapi.Message) string { return msg.Text }
+	step1.ConditionalNext["A"] = &step2
+	step1.ConditionalNext["B"] = &step3
+	branchesWF := NewWorkflow("Branches", "CMD3", &step1)
+	if err := wfc.AddWorkflow(&branchesWF); err != nil {
+		t.Errorf("Expected steps sharing their Name on different branches to be accepted but got %v", err)
+	}
+}
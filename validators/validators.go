@@ -0,0 +1,241 @@
+// Package validators provides reusable input validation functions
+// for the ValidateInputFunc of TBotWorkflowStep and TBotWorkflowController.
+//
+// Validators can be combined with All and Any:
+//
+//	step.ValidateInputFunc = validators.All(
+//		validators.Required(),
+//		validators.MaxLength(254),
+//		validators.Email(),
+//	)
+//
+// The error texts sent to the user are defined in Messages and can be overridden
+// globally, or for a single validator with WithMessage and WithMessageFunc.
+package validators
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Func is the signature of the ValidateInputFunc of TBotWorkflowStep and TBotWorkflowController.
+// If the validation fails (function returns false), the string returned by the function is sent to the user.
+type Func = func(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool)
+
+// Texts are the format strings of the error texts sent to the user.
+// The first argument of each format is the user input, followed by the limits of the length and range validators.
+// Use explicit argument indexes (e.g. "%[2]d") to leave out the user input.
+// The Required text is not a format since the input is empty.
+type Texts struct {
+	Required   string
+	MinLength  string
+	MaxLength  string
+	Regexp     string
+	IntRange   string
+	FloatRange string
+	Email      string
+	URL        string
+	Phone      string
+	Date       string
+	OneOf      string
+	Keyboard   string
+}
+
+// Messages are the error texts used by all the validators.
+// Change them at start-up to translate the default texts.
+var Messages = Texts{
+	Required:   "Please enter a value",
+	MinLength:  "Invalid input %s. Please enter at least %d characters",
+	MaxLength:  "Invalid input %s. Please enter at most %d characters",
+	Regexp:     "Invalid input %s. Please try again",
+	IntRange:   "Invalid input %s. Please enter a whole number between %d and %d",
+	FloatRange: "Invalid input %s. Please enter a number between %g and %g",
+	Email:      "Invalid input %s. Please enter an e-mail address",
+	URL:        "Invalid input %s. Please enter a web address",
+	Phone:      "Invalid input %s. Please enter a phone number",
+	Date:       "Invalid input %s. Please enter a date",
+	OneOf:      "Invalid input %s. Please select one of the options",
+	Keyboard:   "Invalid input %s. Please try again",
+}
+
+var (
+	phoneSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "")
+	phoneRegexp     = regexp.MustCompile(`^\+?[0-9]{6,15}$`)
+)
+
+// check returns a Func which validates the trimmed text of the message with ok.
+// The error text is read from Messages when the validation fails, so that later changes to Messages apply.
+func check(ok func(text string) bool, text func() string, a ...interface{}) Func {
+	return func(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool) {
+		input := strings.TrimSpace(msg.Text)
+		if ok(input) {
+			return "", true
+		}
+		return fmt.Sprintf(text(), append([]interface{}{msg.Text}, a...)...), false
+	}
+}
+
+// Required rejects empty and whitespace-only inputs.
+func Required() Func {
+	return func(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool) {
+		if strings.TrimSpace(msg.Text) == "" {
+			return Messages.Required, false
+		}
+		return "", true
+	}
+}
+
+// MinLength rejects inputs shorter than n characters.
+func MinLength(n int) Func {
+	return check(func(text string) bool {
+		return utf8.RuneCountInString(text) >= n
+	}, func() string { return Messages.MinLength }, n)
+}
+
+// MaxLength rejects inputs longer than n characters.
+func MaxLength(n int) Func {
+	return check(func(text string) bool {
+		return utf8.RuneCountInString(text) <= n
+	}, func() string { return Messages.MaxLength }, n)
+}
+
+// Regexp rejects inputs not matching re.
+func Regexp(re *regexp.Regexp) Func {
+	return check(re.MatchString, func() string { return Messages.Regexp })
+}
+
+// IntRange rejects inputs which are not whole numbers between min and max, inclusive.
+func IntRange(min int64, max int64) Func {
+	return check(func(text string) bool {
+		i, err := strconv.ParseInt(text, 10, 64)
+		return err == nil && i >= min && i <= max
+	}, func() string { return Messages.IntRange }, min, max)
+}
+
+// FloatRange rejects inputs which are not numbers between min and max, inclusive.
+func FloatRange(min float64, max float64) Func {
+	return check(func(text string) bool {
+		f, err := strconv.ParseFloat(text, 64)
+		return err == nil && f >= min && f <= max
+	}, func() string { return Messages.FloatRange }, min, max)
+}
+
+// Email rejects inputs which are not a plain e-mail address, e.g. "name@example.com".
+func Email() Func {
+	return check(func(text string) bool {
+		address, err := mail.ParseAddress(text)
+		return err == nil && address.Address == text
+	}, func() string { return Messages.Email })
+}
+
+// URL rejects inputs which are not absolute http or https URLs.
+func URL() Func {
+	return check(func(text string) bool {
+		u, err := url.ParseRequestURI(text)
+		return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+	}, func() string { return Messages.URL })
+}
+
+// Phone rejects inputs which are not phone numbers of 6 to 15 digits,
+// optionally starting with "+" and separated by spaces, dashes, dots or parentheses.
+func Phone() Func {
+	return check(func(text string) bool {
+		return phoneRegexp.MatchString(phoneSeparators.Replace(text))
+	}, func() string { return Messages.Phone })
+}
+
+// Date rejects inputs which cannot be parsed with the time layout, e.g. "02.01.2006".
+func Date(layout string) Func {
+	return check(func(text string) bool {
+		_, err := time.Parse(layout, text)
+		return err == nil
+	}, func() string { return Messages.Date })
+}
+
+// OneOf rejects inputs which are not one of the values.
+func OneOf(values ...string) Func {
+	return check(func(text string) bool {
+		for _, value := range values {
+			if text == value {
+				return true
+			}
+		}
+		return false
+	}, func() string { return Messages.OneOf })
+}
+
+// KeyboardIgnoreCase rejects inputs which do not match the text of one of the keyboard buttons, ignoring case.
+// Inputs are accepted if there is no keyboard.
+func KeyboardIgnoreCase() Func {
+	return func(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool) {
+		if kb == nil || len(kb.Keyboard) == 0 {
+			return "", true
+		}
+		input := strings.TrimSpace(msg.Text)
+		for _, row := range kb.Keyboard {
+			for _, button := range row {
+				if strings.EqualFold(input, button.Text) {
+					return "", true
+				}
+			}
+		}
+		return fmt.Sprintf(Messages.Keyboard, msg.Text), false
+	}
+}
+
+// All accepts the input if all the validators accept it.
+// The error text of the first validator rejecting the input is sent to the user.
+func All(validators ...Func) Func {
+	return func(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool) {
+		for _, validate := range validators {
+			if text, ok := validate(msg, kb); !ok {
+				return text, false
+			}
+		}
+		return "", true
+	}
+}
+
+// Any accepts the input if any of the validators accepts it.
+// The error text of the first validator is sent to the user if all of them reject the input.
+func Any(validators ...Func) Func {
+	return func(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool) {
+		firstText := ""
+		for i, validate := range validators {
+			text, ok := validate(msg, kb)
+			if ok {
+				return "", true
+			}
+			if i == 0 {
+				firstText = text
+			}
+		}
+		return firstText, len(validators) == 0
+	}
+}
+
+// WithMessage replaces the error text of the validator with text.
+func WithMessage(validate Func, text string) Func {
+	return WithMessageFunc(validate, func(msg *tgbotapi.Message) string {
+		return text
+	})
+}
+
+// WithMessageFunc replaces the error text of the validator with the text returned by textFunc,
+// e.g. to reply in the language of the user given by msg.From.LanguageCode.
+func WithMessageFunc(validate Func, textFunc func(msg *tgbotapi.Message) string) Func {
+	return func(msg *tgbotapi.Message, kb *tgbotapi.ReplyKeyboardMarkup) (string, bool) {
+		if _, ok := validate(msg, kb); !ok {
+			return textFunc(msg), false
+		}
+		return "", true
+	}
+}
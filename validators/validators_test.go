@@ -0,0 +1,113 @@
+package validators
+
+import (
+	"regexp"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestValidators(t *testing.T) {
+	kb := tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButton("Main Hall"),
+			tgbotapi.NewKeyboardButton("Bedroom"),
+		),
+	)
+
+	tests := []struct {
+		name         string
+		validate     Func
+		text         string
+		expectedOK   bool
+		expectedText string
+	}{
+		{name: "Required", validate: Required(), text: "value", expectedOK: true},
+		{name: "RequiredEmpty", validate: Required(), text: "  ", expectedText: "Please enter a value"},
+		{name: "MinLength", validate: MinLength(3), text: "äöü", expectedOK: true},
+		{name: "MinLengthShort", validate: MinLength(3), text: "ab",
+			expectedText: "Invalid input ab. Please enter at least 3 characters"},
+		{name: "MaxLength", validate: MaxLength(3), text: "abc", expectedOK: true},
+		{name: "MaxLengthLong", validate: MaxLength(3), text: "abcd",
+			expectedText: "Invalid input abcd. Please enter at most 3 characters"},
+		{name: "Regexp", validate: Regexp(regexp.MustCompile(`^[A-Z]{3}$`)), text: "ABC", expectedOK: true},
+		{name: "RegexpMismatch", validate: Regexp(regexp.MustCompile(`^[A-Z]{3}$`)), text: "abc",
+			expectedText: "Invalid input abc. Please try again"},
+		{name: "IntRange", validate: IntRange(16, 30), text: " 21 ", expectedOK: true},
+		{name: "IntRangeOutside", validate: IntRange(16, 30), text: "31",
+			expectedText: "Invalid input 31. Please enter a whole number between 16 and 30"},
+		{name: "IntRangeNotInt", validate: IntRange(16, 30), text: "21.5",
+			expectedText: "Invalid input 21.5. Please enter a whole number between 16 and 30"},
+		{name: "FloatRange", validate: FloatRange(0, 1), text: "0.5", expectedOK: true},
+		{name: "FloatRangeOutside", validate: FloatRange(0, 1), text: "1.5",
+			expectedText: "Invalid input 1.5. Please enter a number between 0 and 1"},
+		{name: "Email", validate: Email(), text: "unit@test.com", expectedOK: true},
+		{name: "EmailDisplayName", validate: Email(), text: "Unit <unit@test.com>",
+			expectedText: "Invalid input Unit <unit@test.com>. Please enter an e-mail address"},
+		{name: "URL", validate: URL(), text: "https://example.com/path", expectedOK: true},
+		{name: "URLRelative", validate: URL(), text: "example.com",
+			expectedText: "Invalid input example.com. Please enter a web address"},
+		{name: "URLScheme", validate: URL(), text: "ftp://example.com",
+			expectedText: "Invalid input ftp://example.com. Please enter a web address"},
+		{name: "Phone", validate: Phone(), text: "+49 (151) 123-45678", expectedOK: true},
+		{name: "PhoneLetters", validate: Phone(), text: "call me",
+			expectedText: "Invalid input call me. Please enter a phone number"},
+		{name: "Date", validate: Date("02.01.2006"), text: "24.12.2021", expectedOK: true},
+		{name: "DateLayout", validate: Date("02.01.2006"), text: "2021-12-24",
+			expectedText: "Invalid input 2021-12-24. Please enter a date"},
+		{name: "OneOf", validate: OneOf("S", "M", "L"), text: "M", expectedOK: true},
+		{name: "OneOfOther", validate: OneOf("S", "M", "L"), text: "XL",
+			expectedText: "Invalid input XL. Please select one of the options"},
+		{name: "KeyboardIgnoreCase", validate: KeyboardIgnoreCase(), text: "main hall", expectedOK: true},
+		{name: "KeyboardIgnoreCaseOther", validate: KeyboardIgnoreCase(), text: "Kitchen",
+			expectedText: "Invalid input Kitchen. Please try again"},
+		{name: "All", validate: All(Required(), MaxLength(20), Email()), text: "unit@test.com", expectedOK: true},
+		{name: "AllFirstFailure", validate: All(Required(), MaxLength(5), Email()), text: "unit@test.com",
+			expectedText: "Invalid input unit@test.com. Please enter at most 5 characters"},
+		{name: "Any", validate: Any(Email(), Phone()), text: "+4915112345678", expectedOK: true},
+		{name: "AnyNone", validate: Any(Email(), Phone()), text: "unknown",
+			expectedText: "Invalid input unknown. Please enter an e-mail address"},
+		{name: "WithMessage", validate: WithMessage(Email(), "Bitte eine E-Mail-Adresse eingeben"), text: "x",
+			expectedText: "Bitte eine E-Mail-Adresse eingeben"},
+		{name: "WithMessageValid", validate: WithMessage(Email(), "Bitte eine E-Mail-Adresse eingeben"),
+			text: "unit@test.com", expectedOK: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			msg := tgbotapi.Message{Text: tc.text}
+			text, ok := tc.validate(&msg, &kb)
+			if ok != tc.expectedOK || text != tc.expectedText {
+				t.Errorf("Expected %v/%q but got %v/%q", tc.expectedOK, tc.expectedText, ok, text)
+			}
+		})
+	}
+}
+
+func TestWithMessageFunc(t *testing.T) {
+	texts := map[string]string{"de": "Bitte eine Zahl eingeben", "en": "Please enter a number"}
+	validate := WithMessageFunc(IntRange(1, 10), func(msg *tgbotapi.Message) string {
+		return texts[msg.From.LanguageCode]
+	})
+
+	msg := tgbotapi.Message{Text: "zehn", From: &tgbotapi.User{LanguageCode: "de"}}
+	if text, ok := validate(&msg, nil); ok || text != texts["de"] {
+		t.Errorf("Expected %q but got %v/%q", texts["de"], ok, text)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	defaults := Messages
+	defer func() { Messages = defaults }()
+
+	Messages.IntRange = "Bitte eine Zahl von %[2]d bis %[3]d eingeben"
+	validate := IntRange(16, 30)
+
+	msg := tgbotapi.Message{Text: "40"}
+	if text, ok := validate(&msg, nil); ok || text != "Bitte eine Zahl von 16 bis 30 eingeben" {
+		t.Errorf("Expected the overridden message but got %v/%q", ok, text)
+	}
+	if text, ok := KeyboardIgnoreCase()(&msg, nil); !ok || text != "" {
+		t.Errorf("Expected input accepted without keyboard but got %v/%q", ok, text)
+	}
+}
@@ -0,0 +1,381 @@
+package tbotworkflow
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// Default layout used to parse ValueTime inputs.
+	defaultTimeLayout string = "2006-01-02"
+)
+
+// ValueType is the type of value a TBotWorkflowStep parses the user input into.
+type ValueType string
+
+const (
+	// ValueString keeps the user input as text. This is the default for steps without a ValueType.
+	ValueString ValueType = "string"
+	// ValueInt parses the user input as a whole number.
+	ValueInt ValueType = "int"
+	// ValueFloat parses the user input as a decimal number.
+	ValueFloat ValueType = "float"
+	// ValueBool parses the user input as yes/no, true/false or on/off.
+	ValueBool ValueType = "bool"
+	// ValueTime parses the user input using the TimeLayout of the step.
+	ValueTime ValueType = "time"
+	// ValueEnum accepts one of the EnumValues of the step, ignoring case.
+	ValueEnum ValueType = "enum"
+	// ValueEmail accepts an e-mail address. The address without the display name is captured.
+	ValueEmail ValueType = "email"
+	// ValuePhone accepts a phone number as text or a shared contact. The number without separators is captured.
+	// Set the InputKind to InputContact to offer a RequestContact button, so that typed numbers are not validated against the KB.
+	ValuePhone ValueType = "phone"
+	// ValueFile accepts a photo, document, voice or video.
+	ValueFile ValueType = "file"
+)
+
+var (
+	defaultInvalidValueReplyTexts = map[ValueType]string{
+		ValueInt:   "Invalid input %s. Please enter a whole number",
+		ValueFloat: "Invalid input %s. Please enter a number",
+		ValueBool:  "Invalid input %s. Please answer yes or no",
+		ValueTime:  "Invalid input %s. Please enter a date",
+		ValueEnum:  "Invalid input %s. Please select one of the options",
+		ValueEmail: "Invalid input %s. Please enter an e-mail address",
+		ValuePhone: "Invalid input %s. Please enter a phone number",
+		ValueFile:  "Invalid input %s. Please send a file",
+	}
+
+	boolValues = map[string]bool{
+		"yes": true, "y": true, "true": true, "on": true, "1": true,
+		"no": false, "n": false, "false": false, "off": false, "0": false,
+	}
+
+	phoneSeparators = strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "")
+	phoneRegexp     = regexp.MustCompile(`^\+?[0-9]{6,15}$`)
+)
+
+func (s *TBotWorkflowStep) valueType() ValueType {
+	if s.ValueType == "" {
+		return ValueString
+	}
+	return s.ValueType
+}
+
+func (s *TBotWorkflowStep) timeLayout() string {
+	if s.TimeLayout == "" {
+		return defaultTimeLayout
+	}
+	return s.TimeLayout
+}
+
+// enumValues returns the EnumValues of the step, defaulting to the buttons of the keyboard.
+func (s *TBotWorkflowStep) enumValues() []string {
+	if len(s.EnumValues) > 0 {
+		return s.EnumValues
+	}
+	values := []string{}
+	if s.InlineKB != nil {
+		for _, row := range s.InlineKB.InlineKeyboard {
+			for _, button := range row {
+				if button.CallbackData != nil {
+					values = append(values, *button.CallbackData)
+				}
+			}
+		}
+	} else if s.KB != nil {
+		for _, row := range s.KB.Keyboard {
+			for _, button := range row {
+				values = append(values, button.Text)
+			}
+		}
+	}
+	return values
+}
+
+// parseInput returns the structured input carried by the message parsed into the ValueType of the step.
+// If the input cannot be parsed, the string returned by this method is sent to the user.
+func (w *TBotWorkflowController) parseInput(msg *tgbotapi.Message, step *TBotWorkflowStep, lang string) (InputValue, string, bool) {
+	input := newInputValue(msg)
+	if step.ValueType == "" {
+		return input, "", true
+	}
+
+	input.Type = step.ValueType
+	if err := input.parse(step); err != nil {
+		w.Logger.Printf("User input: %s cannot be parsed as %s. Error: %v", input.String(), step.ValueType, err)
+		if step.InvalidValueReplyText != "" {
+			return input, w.translate(lang, step.InvalidValueReplyText), false
+		}
+		return input, fmt.Sprintf(w.translate(lang, defaultInvalidValueReplyTexts[step.valueType()]), input.String()), false
+	}
+	return input, "", true
+}
+
+func (v *InputValue) parse(step *TBotWorkflowStep) error {
+	text := strings.TrimSpace(v.Text)
+	var err error
+	switch v.Type {
+	case ValueString:
+	case ValueInt:
+		v.Int, err = strconv.ParseInt(text, 10, 64)
+	case ValueFloat:
+		v.Float, err = strconv.ParseFloat(text, 64)
+	case ValueBool:
+		v.Bool, err = parseBool(text)
+	case ValueTime:
+		v.Time, err = time.Parse(step.timeLayout(), text)
+	case ValueEnum:
+		for _, value := range step.enumValues() {
+			if strings.EqualFold(text, value) {
+				v.Normalized = value
+				return nil
+			}
+		}
+		err = fmt.Errorf("not one of %v", step.enumValues())
+	case ValueEmail:
+		var address *mail.Address
+		if address, err = mail.ParseAddress(text); err == nil {
+			v.Normalized = address.Address
+		}
+	case ValuePhone:
+		if v.Kind == InputContact {
+			text = v.PhoneNumber
+		}
+		phone := phoneSeparators.Replace(text)
+		if !phoneRegexp.MatchString(phone) {
+			return fmt.Errorf("invalid phone number %q", text)
+		}
+		v.Normalized = phone
+	case ValueFile:
+		if v.FileID == "" {
+			return fmt.Errorf("no file in %s input", v.Kind)
+		}
+	default:
+		return fmt.Errorf("unknown value type %q", v.Type)
+	}
+	return err
+}
+
+func parseBool(s string) (bool, error) {
+	if b, found := boolValues[strings.ToLower(s)]; found {
+		return b, nil
+	}
+	return false, fmt.Errorf("invalid bool %q", s)
+}
+
+// String returns the user input for the key as text.
+// Returns ErrInputNotFound if the user did not provide an input for the key.
+func (ui *UserInputs) String(key string) (string, error) {
+	value, found := ui.Data[key]
+	if !found {
+		return "", fmt.Errorf("%w for Key: %s", ErrInputNotFound, key)
+	}
+	return value, nil
+}
+
+// Int returns the user input for the key as a whole number.
+// Inputs of steps without the ValueInt type are parsed from the text.
+func (ui *UserInputs) Int(key string) (int64, error) {
+	if input, found := ui.Inputs[key]; found && input.Type == ValueInt {
+		return input.Int, nil
+	}
+	value, err := ui.String(key)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("input for Key: %s: %w", key, err)
+	}
+	return i, nil
+}
+
+// Float returns the user input for the key as a decimal number.
+// Inputs of steps without the ValueFloat or ValueInt type are parsed from the text.
+func (ui *UserInputs) Float(key string) (float64, error) {
+	if input, found := ui.Inputs[key]; found {
+		switch input.Type {
+		case ValueFloat:
+			return input.Float, nil
+		case ValueInt:
+			return float64(input.Int), nil
+		}
+	}
+	value, err := ui.String(key)
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("input for Key: %s: %w", key, err)
+	}
+	return f, nil
+}
+
+// Bool returns the user input for the key as a bool.
+// Inputs of steps without the ValueBool type are parsed from the text.
+func (ui *UserInputs) Bool(key string) (bool, error) {
+	if input, found := ui.Inputs[key]; found && input.Type == ValueBool {
+		return input.Bool, nil
+	}
+	value, err := ui.String(key)
+	if err != nil {
+		return false, err
+	}
+	b, err := parseBool(strings.TrimSpace(value))
+	if err != nil {
+		return false, fmt.Errorf("input for Key: %s: %w", key, err)
+	}
+	return b, nil
+}
+
+// Time returns the user input for the key as a time.
+// Inputs of steps without the ValueTime type are parsed from the text as RFC 3339 or "2006-01-02".
+func (ui *UserInputs) Time(key string) (time.Time, error) {
+	if input, found := ui.Inputs[key]; found && input.Type == ValueTime {
+		return input.Time, nil
+	}
+	value, err := ui.String(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	value = strings.TrimSpace(value)
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t, err = time.Parse(defaultTimeLayout, value)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("input for Key: %s: %w", key, err)
+	}
+	return t, nil
+}
+
+// File returns the user input for the key if it is a photo, document, voice or video.
+func (ui *UserInputs) File(key string) (InputValue, error) {
+	input, found := ui.Inputs[key]
+	if !found {
+		return InputValue{}, fmt.Errorf("%w for Key: %s", ErrInputNotFound, key)
+	}
+	if input.FileID == "" {
+		return InputValue{}, fmt.Errorf("input for Key: %s is a %s, not a file", key, input.Kind)
+	}
+	return input, nil
+}
+
+// Decode fills the fields of the struct pointed to by into with the user inputs.
+// The "Key" of the input is taken from the `tbot:"Key"` tag of the field, or the field name if there is no tag.
+// Fields tagged `tbot:"-"` and fields without a user input are left untouched.
+// Supported field types are string, bool, all int, uint and float types, time.Time and InputValue.
+// The inputs of repeatable steps are decoded into slices of these types.
+func (ui *UserInputs) Decode(into interface{}) error {
+	rv := reflect.ValueOf(into)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("decode into %T: not a pointer to a struct", into)
+	}
+
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		key := field.Tag.Get("tbot")
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			key = field.Name
+		}
+		if list, found := ui.ListInputs[key]; found && rv.Field(i).Kind() == reflect.Slice {
+			if err := ui.decodeList(key, list, rv.Field(i)); err != nil {
+				return fmt.Errorf("decode field %s: %w", field.Name, err)
+			}
+			continue
+		}
+		if _, found := ui.Data[key]; !found {
+			continue
+		}
+		if err := ui.decodeField(key, rv.Field(i)); err != nil {
+			return fmt.Errorf("decode field %s: %w", field.Name, err)
+		}
+	}
+	return nil
+}
+
+// decodeList decodes each input of a repeatable step into an element of the slice.
+func (ui *UserInputs) decodeList(key string, list []InputValue, fv reflect.Value) error {
+	slice := reflect.MakeSlice(fv.Type(), len(list), len(list))
+	for i, input := range list {
+		item := &UserInputs{
+			Data:   map[string]string{key: input.String()},
+			Inputs: map[string]InputValue{key: input},
+		}
+		if err := item.decodeField(key, slice.Index(i)); err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+	fv.Set(slice)
+	return nil
+}
+
+func (ui *UserInputs) decodeField(key string, fv reflect.Value) error {
+	switch fv.Interface().(type) {
+	case time.Time:
+		t, err := ui.Time(key)
+		if err == nil {
+			fv.Set(reflect.ValueOf(t))
+		}
+		return err
+	case InputValue:
+		fv.Set(reflect.ValueOf(ui.Inputs[key]))
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(ui.Data[key])
+	case reflect.Bool:
+		b, err := ui.Bool(key)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := ui.Int(key)
+		if err != nil {
+			return err
+		}
+		if fv.OverflowInt(i) {
+			return fmt.Errorf("input for Key: %s overflows %s", key, fv.Type())
+		}
+		fv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := ui.Int(key)
+		if err != nil {
+			return err
+		}
+		if i < 0 || fv.OverflowUint(uint64(i)) {
+			return fmt.Errorf("input for Key: %s overflows %s", key, fv.Type())
+		}
+		fv.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		f, err := ui.Float(key)
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %s", fv.Type())
+	}
+	return nil
+}
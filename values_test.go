@@ -0,0 +1,237 @@
+package tbotworkflow
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestTypedInputs(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	typedWF := newTypedWorkflow("CMD1")
+	if err := wfc.AddWorkflow(&typedWF); err != nil {
+		t.Fatalf("Failed adding workflow. Error: %v", err)
+	}
+
+	tests := []struct {
+		text           string
+		expectedReply  string
+		expectedResult Result
+	}{
+		{text: "/CMD1", expectedReply: "How many guests?", expectedResult: ResultInProgress},
+		{text: "two", expectedReply: "Invalid input two. Please enter a whole number", expectedResult: ResultValidationFailed},
+		{text: " 2 ", expectedReply: "Budget per guest?", expectedResult: ResultInProgress},
+		{text: "12.5", expectedReply: "Vegetarian?", expectedResult: ResultInProgress},
+		{text: "maybe", expectedReply: "Invalid input maybe. Please answer yes or no", expectedResult: ResultValidationFailed},
+		{text: "Yes", expectedReply: "Date?", expectedResult: ResultInProgress},
+		{text: "2021-12-24", expectedReply: "Please use DD.MM.YYYY", expectedResult: ResultValidationFailed},
+		{text: "24.12.2021", expectedReply: "Table?", expectedResult: ResultInProgress},
+		{text: "Kitchen", expectedReply: "Invalid input Kitchen. Please select one of the options", expectedResult: ResultValidationFailed},
+		{text: "terrace", expectedReply: "E-mail?", expectedResult: ResultInProgress},
+		{text: "unit.test", expectedReply: "Invalid input unit.test. Please enter an e-mail address", expectedResult: ResultValidationFailed},
+		{text: "Unit Test <unit@test.com>", expectedReply: "Phone?", expectedResult: ResultInProgress},
+		{text: "call me", expectedReply: "Invalid input call me. Please enter a phone number", expectedResult: ResultValidationFailed},
+		{text: "+49 (151) 123-45678", expectedReply: "Thank you", expectedResult: ResultCompleted},
+	}
+
+	var userInput *UserInputs
+	for i, tc := range tests {
+		msg := mockBotMessage(1, tc.text)
+		if tc.text[0] == '/' {
+			msg = mockBotCommand(1, tc.text)
+		}
+		var result Result
+		userInput, result, _ = wfc.ExecuteE(&msg, mockSendFunc)
+		if result != tc.expectedResult {
+			t.Errorf("Step %d/Input:%s Expected result %v but got %v", i+1, tc.text, tc.expectedResult, result)
+		}
+		// The step is repeated after the invalid input reply.
+		reply := sentMsgs[len(sentMsgs)-1].Text
+		if result == ResultValidationFailed {
+			reply = sentMsgs[len(sentMsgs)-2].Text
+		}
+		if reply != tc.expectedReply {
+			t.Errorf("Step %d/Input:%s Expected reply %s but got %s", i+1, tc.text, tc.expectedReply, reply)
+		}
+	}
+	if userInput == nil {
+		t.Fatal("Expected workflow to complete")
+	}
+
+	if guests, err := userInput.Int("Guests"); err != nil || guests != 2 {
+		t.Errorf("Expected 2 guests but got %d/%v", guests, err)
+	}
+	if budget, err := userInput.Float("Budget"); err != nil || budget != 12.5 {
+		t.Errorf("Expected budget 12.5 but got %f/%v", budget, err)
+	}
+	if veggie, err := userInput.Bool("Veggie"); err != nil || !veggie {
+		t.Errorf("Expected vegetarian but got %v/%v", veggie, err)
+	}
+	expectedDate := time.Date(2021, 12, 24, 0, 0, 0, 0, time.UTC)
+	if date, err := userInput.Time("Date"); err != nil || !date.Equal(expectedDate) {
+		t.Errorf("Expected date %v but got %v/%v", expectedDate, date, err)
+	}
+	expectedData := map[string]string{
+		"Guests": " 2 ",
+		"Table":  "Terrace",
+		"Email":  "unit@test.com",
+		"Phone":  "+4915112345678",
+	}
+	for k, v := range expectedData {
+		if userInput.Data[k] != v {
+			t.Errorf("Expected %s for Key: %s but got %s", v, k, userInput.Data[k])
+		}
+	}
+
+	var booking struct {
+		Guests   uint8     `tbot:"Guests"`
+		Budget   float64   `tbot:"Budget"`
+		Veggie   bool      `tbot:"Veggie"`
+		Date     time.Time `tbot:"Date"`
+		Table    string
+		Email    string     `tbot:"Email"`
+		Phone    InputValue `tbot:"Phone"`
+		Comments string     `tbot:"-"`
+		ignored  string
+	}
+	booking.Comments = "untouched"
+	if err := userInput.Decode(&booking); err != nil {
+		t.Fatalf("Failed decoding user inputs. Error: %v", err)
+	}
+	if booking.Guests != 2 || booking.Budget != 12.5 || !booking.Veggie || !booking.Date.Equal(expectedDate) ||
+		booking.Table != "Terrace" || booking.Email != "unit@test.com" || booking.Phone.Normalized != "+4915112345678" ||
+		booking.Comments != "untouched" || booking.ignored != "" {
+		t.Errorf("Unexpected decoded user inputs %+v", booking)
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func TestUserInputsAccessors(t *testing.T) {
+	ui := UserInputs{
+		Data: map[string]string{
+			"Temp":   "21",
+			"Ratio":  "0.5",
+			"Power":  "on",
+			"When":   "2021-12-24T10:30:00Z",
+			"ACName": "Main Hall",
+		},
+	}
+
+	if temp, err := ui.Int("Temp"); err != nil || temp != 21 {
+		t.Errorf("Expected 21 but got %d/%v", temp, err)
+	}
+	if ratio, err := ui.Float("Ratio"); err != nil || ratio != 0.5 {
+		t.Errorf("Expected 0.5 but got %f/%v", ratio, err)
+	}
+	if power, err := ui.Bool("Power"); err != nil || !power {
+		t.Errorf("Expected true but got %v/%v", power, err)
+	}
+	if when, err := ui.Time("When"); err != nil || when.Hour() != 10 {
+		t.Errorf("Expected 10:30 but got %v/%v", when, err)
+	}
+	if _, err := ui.Int("ACName"); err == nil {
+		t.Error("Expected error parsing Main Hall as int")
+	}
+	if _, err := ui.Int("Missing"); !errors.Is(err, ErrInputNotFound) {
+		t.Errorf("Expected ErrInputNotFound but got %v", err)
+	}
+	if _, err := ui.File("Missing"); !errors.Is(err, ErrInputNotFound) {
+		t.Errorf("Expected ErrInputNotFound but got %v", err)
+	}
+
+	var notStruct string
+	if err := ui.Decode(&notStruct); err == nil {
+		t.Error("Expected error decoding into a string")
+	}
+	var invalid struct {
+		ACName int
+	}
+	if err := ui.Decode(&invalid); err == nil {
+		t.Error("Expected error decoding Main Hall into an int")
+	}
+	var overflow struct {
+		Temp int8 `tbot:"Temp"`
+	}
+	ui.Data["Temp"] = "210"
+	if err := ui.Decode(&overflow); err == nil {
+		t.Error("Expected error decoding 210 into an int8")
+	}
+}
+
+func TestPhoneInput(t *testing.T) {
+	sentMsgs = []tgbotapi.Message{}
+	wfc := NewWorkflowController("WFC")
+	typedPhone := NewWorkflowStep("Typed", "Typed", "Phone?", nil)
+	typedPhone.ValueType = ValuePhone
+	contactKB := tgbotapi.NewReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(tgbotapi.NewKeyboardButtonContact("Share phone number")),
+	)
+	sharedPhone := NewWorkflowStep("Shared", "Shared", "Please share your phone number", &contactKB)
+	sharedPhone.ValueType = ValuePhone
+	sharedPhone.InputKind = InputContact
+	done := NewWorkflowStep("Done", "", "Thank you", nil)
+	typedPhone.Next = &sharedPhone
+	sharedPhone.Next = &done
+	phoneWF := NewWorkflow("WF", "CMD1", &typedPhone)
+	if err := wfc.AddWorkflow(&phoneWF); err != nil {
+		t.Fatalf("Failed adding workflow. Error: %v", err)
+	}
+
+	// A contact is accepted by a text step and a typed number by a contact step.
+	runMessages(wfc, 1, "/CMD1")
+	contactMsg := mockBotMessage(1, "")
+	contactMsg.Contact = &tgbotapi.Contact{PhoneNumber: "+49 151 12345678", FirstName: "Unit", UserID: 1234}
+	if _, result, _ := wfc.ExecuteE(&contactMsg, mockSendFunc); result != ResultInProgress {
+		t.Errorf("Expected the shared contact to be accepted but got %v", result)
+	}
+	locationMsg := mockBotMessage(1, "")
+	locationMsg.Location = &tgbotapi.Location{Latitude: 52.52, Longitude: 13.405}
+	if _, result, _ := wfc.ExecuteE(&locationMsg, mockSendFunc); result != ResultValidationFailed {
+		t.Errorf("Expected a location to be rejected but got %v", result)
+	}
+	userInput, result, _ := runMessages(wfc, 1, "+49 (151) 987-65432")
+	if result != ResultCompleted {
+		t.Fatalf("Expected the typed number to be accepted but got %v", result)
+	}
+	if userInput.Data["Typed"] != "+4915112345678" || userInput.Inputs["Typed"].Kind != InputContact {
+		t.Errorf("Expected the number of the contact but got %+v", userInput.Inputs["Typed"])
+	}
+	if userInput.Data["Shared"] != "+4915198765432" || userInput.Inputs["Shared"].Kind != InputText {
+		t.Errorf("Expected the typed number but got %+v", userInput.Inputs["Shared"])
+	}
+	sentMsgs = []tgbotapi.Message{}
+}
+
+func newTypedWorkflow(cmd string) TBotWorkflow {
+	step1 := NewWorkflowStep("Step1", "Guests", "How many guests?", nil)
+	step1.ValueType = ValueInt
+	step2 := NewWorkflowStep("Step2", "Budget", "Budget per guest?", nil)
+	step2.ValueType = ValueFloat
+	step3 := NewWorkflowStep("Step3", "Veggie", "Vegetarian?", nil)
+	step3.ValueType = ValueBool
+	step4 := NewWorkflowStep("Step4", "Date", "Date?", nil)
+	step4.ValueType = ValueTime
+	step4.TimeLayout = "02.01.2006"
+	step4.InvalidValueReplyText = "Please use DD.MM.YYYY"
+	step5 := NewWorkflowStep("Step5", "Table", "Table?", nil)
+	step5.ValueType = ValueEnum
+	step5.EnumValues = []string{"Inside", "Terrace"}
+	step6 := NewWorkflowStep("Step6", "Email", "E-mail?", nil)
+	step6.ValueType = ValueEmail
+	step7 := NewWorkflowStep("Step7", "Phone", "Phone?", nil)
+	step7.ValueType = ValuePhone
+	step8 := NewWorkflowStep("Step8", "", "Thank you", nil)
+
+	step1.Next = &step2
+	step2.Next = &step3
+	step3.Next = &step4
+	step4.Next = &step5
+	step5.Next = &step6
+	step6.Next = &step7
+	step7.Next = &step8
+
+	return NewWorkflow("WF", cmd, &step1)
+}
@@ -0,0 +1,117 @@
+package tbotworkflow
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// Header carrying the secret_token set with setWebhook.
+	secretTokenHeader string = "X-Telegram-Bot-Api-Secret-Token"
+	// Default maximum size in bytes of the body of a webhook request.
+	defaultMaxWebhookBodySize int64 = 1 << 20
+)
+
+// WebhookHandler is an http.Handler receiving the updates Telegram sends to the webhook of the bot.
+// The updates are processed like the updates received by the Runner, including the OnComplete functions of the workflows.
+type WebhookHandler struct {
+	// Runner processing the updates.
+	Runner *Runner
+	// Function used to send the messages. Defaults to the Send function of the Bot of the Runner.
+	SendFunc SendFunc
+	// Secret token set with setWebhook. Requests without the token in the
+	// X-Telegram-Bot-Api-Secret-Token header are rejected. Set to empty string to accept all requests.
+	SecretToken string
+	// Maximum size in bytes of the body of a webhook request. Larger requests are rejected. Defaults to 1 MiB.
+	MaxBodySize int64
+	// Respond to the webhook request with the last message instead of sending it with the SendFunc.
+	// This saves a request to Telegram, but errors sending the message cannot be detected.
+	// Telegram runs the method of the response after the request is answered, so the messages sent
+	// before are sent with the SendFunc to keep them in order, e.g. the reply to an invalid input
+	// before the step is sent again. Messages uploading files are always sent with the SendFunc.
+	InlineResponse bool
+}
+
+// WebhookHandler returns a WebhookHandler processing the updates with the runner.
+// The Bot of the runner can be nil if the SendFunc of the handler is set.
+func (r *Runner) WebhookHandler(secretToken string) *WebhookHandler {
+	return &WebhookHandler{
+		Runner:      r,
+		SecretToken: secretToken,
+	}
+}
+
+// ServeHTTP decodes the update in the request body and runs it through the workflows.
+// The context of the request is passed to the context aware functions of the workflows.
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := req.Header.Get(secretTokenHeader)
+	if h.SecretToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(h.SecretToken)) != 1 {
+		h.Runner.Controller.Logger.Printf("Rejecting webhook request with invalid secret token")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	maxBodySize := h.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = defaultMaxWebhookBodySize
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxBodySize))
+	if err != nil {
+		h.Runner.Controller.Logger.Printf("Failed reading webhook request. Error: %v", err)
+		if int64(len(body)) >= maxBodySize {
+			http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	var update tgbotapi.Update
+	if err := json.Unmarshal(body, &update); err != nil {
+		h.Runner.Controller.Logger.Printf("Failed decoding webhook update. Error: %v", err)
+		http.Error(w, "invalid update", http.StatusBadRequest)
+		return
+	}
+
+	send := h.SendFunc
+	if send == nil {
+		send = h.Runner.Bot.Send
+	}
+	var inline tgbotapi.Chattable
+	if h.InlineResponse {
+		sendFunc := send
+		send = func(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+			// A later message is sent after the one held back, which is then sent with the SendFunc.
+			if inline != nil {
+				held := inline
+				inline = nil
+				if _, err := sendFunc(held); err != nil {
+					h.Runner.Controller.Logger.Printf("Failed sending message. Error: %v", err)
+				}
+			}
+			if _, upload := c.(tgbotapi.Fileable); upload {
+				return sendFunc(c)
+			}
+			inline = c
+			return tgbotapi.Message{}, nil
+		}
+	}
+
+	h.Runner.process(req.Context(), update, send)
+
+	if inline == nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err := tgbotapi.WriteToHTTPResponse(w, inline); err != nil {
+		h.Runner.Controller.Logger.Printf("Failed writing webhook response. Error: %v", err)
+	}
+}
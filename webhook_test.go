@@ -0,0 +1,97 @@
+package tbotworkflow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	runner := NewRunner(nil, NewWorkflowController("WFC"))
+	var completed *UserInputs
+	seqWF := newSeqWorkflow("CMD1")
+	if err := runner.AddWorkflow(&seqWF, func(ui *UserInputs) { completed = ui }); err != nil {
+		t.Fatalf("Failed adding workflow. Error: %v", err)
+	}
+
+	var sent []tgbotapi.Chattable
+	handler := runner.WebhookHandler("secret")
+	handler.SendFunc = func(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+		sent = append(sent, c)
+		return tgbotapi.Message{}, nil
+	}
+
+	post := func(token string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/telegram", strings.NewReader(body))
+		req.Header.Set(secretTokenHeader, token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	update := func(id int, text string, command bool) string {
+		entities := ""
+		if command {
+			entities = `,"entities":[{"type":"bot_command","offset":0,"length":` + strconv.Itoa(len(text)) + `}]`
+		}
+		return `{"update_id":` + strconv.Itoa(id) + `,"message":{"message_id":` + strconv.Itoa(id) +
+			`,"from":{"id":1234,"username":"UnitTest"},"chat":{"id":1,"type":"private"},"text":"` + text + `"` + entities + `}}`
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/telegram", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected %d for GET but got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+	if rec := post("wrong", update(1, "/CMD1", true)); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected %d for invalid secret token but got %d", http.StatusUnauthorized, rec.Code)
+	}
+	if rec := post("secret", "{"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected %d for invalid update but got %d", http.StatusBadRequest, rec.Code)
+	}
+	handler.MaxBodySize = 64
+	if rec := post("secret", update(1, "/CMD1", true)); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected %d for a too large update but got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+	handler.MaxBodySize = 0
+	if len(sent) != 0 {
+		t.Errorf("Expected rejected requests not to send messages but got %d", len(sent))
+	}
+
+	if rec := post("secret", update(2, "/CMD1", true)); rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Errorf("Expected empty %d response but got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if len(sent) != 1 {
+		t.Fatalf("Expected reply sent with the SendFunc but got %d messages", len(sent))
+	}
+
+	handler.InlineResponse = true
+	rec = post("secret", update(3, "Step1Option1", false))
+	body, _ := url.ParseQuery(rec.Body.String())
+	if rec.Code != http.StatusOK || body.Get("method") != "sendMessage" || body.Get("text") != "Please select another option" {
+		t.Errorf("Expected reply in the webhook response but got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(sent) != 1 {
+		t.Errorf("Expected inline reply not to be sent with the SendFunc but got %d messages", len(sent))
+	}
+
+	// Earlier messages are sent with the SendFunc and the last one is in the webhook response.
+	rec = post("secret", update(4, "Invalid", false))
+	body, _ = url.ParseQuery(rec.Body.String())
+	if body.Get("text") != "Please select another option" {
+		t.Errorf("Expected the step sent again in the webhook response but got %s", rec.Body.String())
+	}
+	if len(sent) != 2 || sent[1].(tgbotapi.MessageConfig).Text != "Invalid input Invalid. Please try again" {
+		t.Errorf("Expected the invalid input reply sent with the SendFunc first but got %v", sent)
+	}
+
+	post("secret", update(5, "Step2Option3", false))
+	if completed == nil || completed.Data["K2"] != "Step2Option3" {
+		t.Errorf("Expected OnComplete called with the UserInputs but got %v", completed)
+	}
+}